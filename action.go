@@ -0,0 +1,330 @@
+package main
+
+import (
+	"local.packages/volume"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Action 名前を付けて keymap から呼び出す操作。arg は "preset 3" の "3" の様に名前に続く引数。
+type Action func(v *RadioState, arg string)
+
+var (
+	actions = make(map[string]Action)
+)
+
+// RegisterAction 操作を登録する。同じ名前で登録すると置き換える。
+func RegisterAction(name string, a Action) {
+	actions[name] = a
+}
+
+// ActionNames 登録されている操作の名前を返す
+func ActionNames() []string {
+	names := make([]string, 0, len(actions))
+	for k := range actions {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseAction "preset 3" を名前と引数に分ける
+func parseAction(s string) (string, string) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(s), " ")
+	return name, strings.TrimSpace(arg)
+}
+
+// RunAction 名前の操作を実行し、transitions に従って遷移する。操作が成り立たなければ遷移しない。
+// ループを継続する場合は false、中断する場合は true を返す
+func (v *RadioState) RunAction(s string) bool {
+	name, arg := parseAction(s)
+	if name == "" {
+		return false
+	}
+	a, ok := actions[name]
+	if !ok {
+		log.Printf("%s: 登録されていない操作", name)
+		return false
+	}
+	from := v.currState
+	t, ok := lookupTransition(v, from, name)
+	v.quit = false
+	v.stay = false
+	a(v, arg)
+	if ok && !v.stay {
+		v.TransitionState(t.Next)
+	} else {
+		v.resetIdle()
+	}
+	return v.quit
+}
+
+// registerActions 操作を登録する
+func registerActions() {
+	RegisterAction("none", actTransition)
+	RegisterAction("tune", (*RadioState).actTune)
+	RegisterAction("preset", (*RadioState).actPreset)
+	RegisterAction("next", (*RadioState).actNext)
+	RegisterAction("prev", (*RadioState).actPrev)
+	RegisterAction("nextgroup", (*RadioState).actNextGroup)
+	RegisterAction("prevgroup", (*RadioState).actPrevGroup)
+	RegisterAction("volup", (*RadioState).actVolUp)
+	RegisterAction("voldown", (*RadioState).actVolDown)
+	RegisterAction("mute", (*RadioState).actMute)
+	RegisterAction("off", (*RadioState).actOff)
+	RegisterAction("toggle", (*RadioState).actToggle)
+	RegisterAction("shutdown", (*RadioState).actShutdown)
+	RegisterAction("home", (*RadioState).actHome)
+	RegisterAction("station", (*RadioState).actStation)
+	RegisterAction("nexttrack", (*RadioState).actNextTrack)
+	RegisterAction("prevtrack", (*RadioState).actPrevTrack)
+	RegisterAction("tunemode", (*RadioState).actTuneMode)
+	RegisterAction("function", actTransition)
+	RegisterAction("alarmcycle", (*RadioState).actAlarmCycle)
+	RegisterAction("alarmmin", actTransition)
+	RegisterAction("sleep", (*RadioState).actSleep)
+	RegisterAction("diagnostics", actTransition)
+	RegisterAction("search", actTransition)
+	RegisterAction("find", (*RadioState).actFind)
+	RegisterAction("add", (*RadioState).actAdd)
+	RegisterAction("play", (*RadioState).actPlay)
+	RegisterAction("irlearn", actTransition)
+	RegisterAction("irclear", (*RadioState).actIRClear)
+	RegisterAction("settings", actTransition)
+	RegisterAction("select", (*RadioState).actSelect)
+	RegisterAction("back", (*RadioState).actBack)
+	RegisterAction("swap", (*RadioState).actSwap)
+	RegisterAction("history", actTransition)
+}
+
+// actTransition 遷移だけを行う操作（遷移先は transitions で決める）
+func actTransition(v *RadioState, arg string) {}
+
+// actTune 選んでいる局を再生する。選局中であればオンデマンドの番組は回の一覧を読み込む
+// （読み込む間は選局に留まる）。
+func (v *RadioState) actTune(arg string) {
+	if v.currState == stateTuneMode && guardEpisodic.Test(v) {
+		v.selectEpisode()
+		v.stay = true
+		return
+	}
+	tune()
+}
+
+// actPreset 局リストの arg 番目（1から）の局を再生する
+func (v *RadioState) actPreset(arg string) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > v.stationListLen {
+		log.Printf("preset %s: 局リストにありません", arg)
+		v.stay = true
+		return
+	}
+	v.pos = n - 1
+	tune()
+}
+
+// actNext 状態に応じて次の項目へ進める（再生中は次の局を再生する。局、アラーム時刻、検索条件、検索結果、回、再生位置、診断の頁、学習する操作、設定メニュー、履歴は新しい方の局）
+func (v *RadioState) actNext(arg string) {
+	switch v.currState {
+	case stateVolumeSet:
+		v.NextTune()
+		tune()
+	case stateTuneMode:
+		// 他の局へ回したら選局中の局は取り消す
+		CancelTune()
+		v.NextTune()
+		v.showTuneLabel()
+	case stateAlarmHourSet, stateAlarmMinSet:
+		v.AlarmTimeInc()
+		lcd.PrintWithPos(0, uint8(1), []byte(v.GetStateString(1)))
+	case stateDirQuery:
+		v.nextDirQuery(1)
+	case stateDirBrowse:
+		v.nextDirResult(1)
+	case stateEpisodeSelect:
+		v.nextEpisode(1)
+	case statePlayback:
+		v.playbackStep(1)
+	case stateDiagnostics:
+		v.nextDiagPage(1)
+	case stateIRLearn:
+		v.nextIRPage(1)
+	case stateSettings:
+		v.menu.Step(1)
+		lcd.PrintWithPos(0, uint8(1), []byte(v.GetStateString(1)))
+		if !v.menu.IsEditing() {
+			infomation.Update(0, v.menu.Label())
+		}
+	case stateHistory:
+		v.stepHistory(-1)
+	}
+}
+
+// actPrev 状態に応じて前の項目へ戻す
+func (v *RadioState) actPrev(arg string) {
+	switch v.currState {
+	case stateVolumeSet:
+		v.PriorTune()
+		tune()
+	case stateTuneMode:
+		CancelTune()
+		v.PriorTune()
+		v.showTuneLabel()
+	case stateAlarmHourSet, stateAlarmMinSet:
+		v.AlarmTimeDec()
+		lcd.PrintWithPos(0, uint8(1), []byte(v.GetStateString(1)))
+	case stateDirQuery:
+		v.nextDirQuery(-1)
+	case stateDirBrowse:
+		v.nextDirResult(-1)
+	case stateEpisodeSelect:
+		v.nextEpisode(-1)
+	case statePlayback:
+		v.playbackStep(-1)
+	case stateDiagnostics:
+		v.nextDiagPage(-1)
+	case stateIRLearn:
+		v.nextIRPage(-1)
+	case stateSettings:
+		v.menu.Step(-1)
+		lcd.PrintWithPos(0, uint8(1), []byte(v.GetStateString(1)))
+		if !v.menu.IsEditing() {
+			infomation.Update(0, v.menu.Label())
+		}
+	case stateHistory:
+		v.stepHistory(1)
+	}
+}
+
+// actNextGroup 次のグループの先頭の局を選ぶ
+func (v *RadioState) actNextGroup(arg string) {
+	CancelTune()
+	v.NextGroup()
+	v.showTuneLabel()
+}
+
+// actPrevGroup 前のグループの先頭の局を選ぶ
+func (v *RadioState) actPrevGroup(arg string) {
+	CancelTune()
+	v.PriorGroup()
+	v.showTuneLabel()
+}
+
+// showTuneLabel 選んでいる局を表示する。確定しなければ元の局に戻る。
+func (v *RadioState) showTuneLabel() {
+	infomation.Update(0, v.CurrentStationLabel())
+	v.restoreTimer.Reset(stationRestoreDuration)
+}
+
+// actVolUp 音量を上げる。ラジオが切れていればスイッチを入れる。
+func (v *RadioState) actVolUp(arg string) {
+	if !v.IsRadioEnable() {
+		tune()
+	}
+	volume.Increment()
+}
+
+// actVolDown 音量を下げる。下げきった状態ならラジオを止める。
+func (v *RadioState) actVolDown(arg string) {
+	if guardVolumeMin.Test(v) {
+		mpvStop()
+		return
+	}
+	volume.Decrement()
+}
+
+// actStation 手元の音声ファイルの再生中は音量調整の中で曲送りを始める。それ以外は遷移だけを行う
+func (v *RadioState) actStation(arg string) {
+	if v.currState == stateVolumeSet && guardPlaylist.Test(v) {
+		v.trackSkip = true
+		v.stay = true
+	}
+}
+
+// actNextTrack 手元の音声ファイルの次の曲へ送る
+func (v *RadioState) actNextTrack(arg string) {
+	v.trackStep(1)
+}
+
+// actPrevTrack 手元の音声ファイルの前の曲へ戻す
+func (v *RadioState) actPrevTrack(arg string) {
+	v.trackStep(-1)
+}
+
+// actMute 消音を切り替える
+func (v *RadioState) actMute(arg string) {
+	v.SetMute(!v.muted)
+}
+
+// actOff ラジオを止める
+func (v *RadioState) actOff(arg string) {
+	mpvStop()
+}
+
+// actToggle 鳴っていれば止め、止まっていれば再生する
+func (v *RadioState) actToggle(arg string) {
+	if guardPlaying.Test(v) {
+		mpvStop()
+		return
+	}
+	tune()
+}
+
+// actShutdown 電源を切る
+func (v *RadioState) actShutdown(arg string) {
+	shutdown()
+	v.quit = true
+}
+
+// actHome 入力待ち（ラジオが鳴っていれば音量調整）へ戻る
+func (v *RadioState) actHome(arg string) {
+	if v.radioEnable && v.IsBrowsing() {
+		// 検索結果等の表示を局名に戻す
+		infomation.Update(0, v.CurrentStationName())
+	}
+}
+
+// actTuneMode 選局へ。選んでいる局を表示する
+func (v *RadioState) actTuneMode(arg string) {
+	v.showTuneLabel()
+}
+
+// actAlarmCycle アラームON -> スリープON -> アラーム・スリープON -> ALL OFF -> アラーム時刻の設定
+func (v *RadioState) actAlarmCycle(arg string) {
+	if guardAlarmSleepOn.Test(v) {
+		v.tokeiState = 0
+		return
+	}
+	v.tokeiState++
+	v.tokeiState &= (tokeiAlarmOn | tokeiSleepOn)
+	if (v.tokeiState & tokeiSleepOn) == tokeiSleepOn {
+		// スリープ時刻の設定を行う
+		v.TurnOffTime = timeNow().Add(time.Duration(config.SleepDuration) * time.Minute)
+	}
+}
+
+// actSleep スリープを切り替える
+func (v *RadioState) actSleep(arg string) {
+	v.tokeiState ^= tokeiSleepOn
+	if (v.tokeiState & tokeiSleepOn) == tokeiSleepOn {
+		v.TurnOffTime = timeNow().Add(time.Duration(config.SleepDuration) * time.Minute)
+	}
+}
+
+// actFind 選んだ条件で局を検索する
+func (v *RadioState) actFind(arg string) {
+	v.findStations()
+}
+
+// actAdd 表示中の検索結果を局リストへ加える
+func (v *RadioState) actAdd(arg string) {
+	v.addDirResult()
+}
+
+// actPlay 選んだ回を再生する
+func (v *RadioState) actPlay(arg string) {
+	v.playEpisode()
+}
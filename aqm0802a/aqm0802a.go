@@ -24,6 +24,8 @@ type AQM0802A struct {
 	pin_backlight int
 	isLightOn     bool
 	lightTimer    *time.Timer
+	lightDuration time.Duration
+	lightUntil    time.Time // OneShotLight で点けたバックライトを消す時刻
 	mu            sync.Mutex
 	Config        Config
 }
@@ -162,8 +164,9 @@ func New(bus *i2c.I2C, reset_pin int, backlight_pin int) *AQM0802A {
 		pin_reset:     reset_pin,
 		pin_backlight: backlight_pin,
 		isLightOn:     false,
+		lightDuration: durationOfBackLight,
 	}
-	d.lightTimer = time.AfterFunc(durationOfBackLight, d.LightOff)
+	d.lightTimer = time.AfterFunc(durationOfBackLight, d.lightExpired)
 	return &d
 }
 
@@ -208,20 +211,39 @@ func (d *AQM0802A) LightOff() {
 }
 
 func (d *AQM0802A) IsLightOn() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	return d.isLightOn
 }
 
+// OneShotLight バックライトを点けて lightDuration 後に消す。複数の goroutine から呼んでよい
 func (d *AQM0802A) OneShotLight() {
-	if d.isLightOn {
-		d.lightTimer.Stop()
-	} else {
-		d.LightOn()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.isLightOn {
+		rpio.Pin(d.pin_backlight).High()
+		d.isLightOn = true
 	}
-	// ここに至るまでにタイマーが発動して消灯している可能性があるので
-	// 再度フラグを調べる
-	if d.isLightOn {
-		d.lightTimer.Reset(durationOfBackLight)
+	d.lightUntil = time.Now().Add(d.lightDuration)
+	d.lightTimer.Reset(d.lightDuration)
+}
+
+// lightExpired タイマーの発動と OneShotLight が重なった時は、消す時刻を過ぎていなければ消さない
+func (d *AQM0802A) lightExpired() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if time.Now().Before(d.lightUntil) {
+		return
 	}
+	rpio.Pin(d.pin_backlight).Low()
+	d.isLightOn = false
+}
+
+// SetLightDuration OneShotLight で点けたバックライトを消すまでの時間を設定する
+func (d *AQM0802A) SetLightDuration(t time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lightDuration = t
 }
 
 func (d *AQM0802A) Reset() {
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+	"unicode/utf8"
+)
+
+type TextEncoding int
+
+const (
+	encASCII TextEncoding = iota
+	encUTF8
+	encUTF8BOM
+	encUTF16LE
+	encUTF16BE
+	encShiftJIS
+	encEUCJP
+)
+
+var (
+	encodingName = [...]string{
+		"ASCII",
+		"UTF-8",
+		"UTF-8(BOM)",
+		"UTF-16LE",
+		"UTF-16BE",
+		"Shift-JIS",
+		"EUC-JP",
+	}
+
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+func (e TextEncoding) String() string {
+	return encodingName[e]
+}
+
+// DetectEncoding バイト列の文字コードを推定する
+func DetectEncoding(b []byte) TextEncoding {
+	switch {
+	case bytes.HasPrefix(b, bomUTF8):
+		return encUTF8BOM
+	case bytes.HasPrefix(b, bomUTF16LE):
+		return encUTF16LE
+	case bytes.HasPrefix(b, bomUTF16BE):
+		return encUTF16BE
+	}
+
+	ascii := true
+	for _, c := range b {
+		if c >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return encASCII
+	}
+	if utf8.Valid(b) {
+		return encUTF8
+	}
+
+	// Shift-JIS と EUC-JP はどちらとしても解釈できる並びがあるので、
+	// それらしい文字（かな・全角英数字・漢字）の数で判定する。同点なら Shift-JIS とする。
+	if scoreEUCJP(b) > scoreShiftJIS(b) {
+		return encEUCJP
+	}
+	return encShiftJIS
+}
+
+// scoreShiftJIS Shift-JIS として解釈した場合のもっともらしさを返す
+func scoreShiftJIS(b []byte) int {
+	score := 0
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case c < 0x80:
+			// ASCII
+		case c >= 0xa1 && c <= 0xdf:
+			// 半角カナ
+			score++
+		case (c >= 0x81 && c <= 0x9f) || (c >= 0xe0 && c <= 0xfc):
+			if i+1 >= len(b) {
+				return score - 1
+			}
+			t := b[i+1]
+			if t < 0x40 || t == 0x7f || t > 0xfc {
+				score -= 2
+				continue
+			}
+			i++
+			switch {
+			case c == 0x82 || c == 0x83:
+				// ひらがな・カタカナ
+				score += 2
+			case c >= 0x88 && c <= 0x98:
+				// 第一水準漢字
+				score++
+			}
+		default:
+			score -= 2
+		}
+	}
+	return score
+}
+
+// scoreEUCJP EUC-JP として解釈した場合のもっともらしさを返す
+func scoreEUCJP(b []byte) int {
+	score := 0
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case c < 0x80:
+			// ASCII
+		case c == 0x8e:
+			// 半角カナ
+			if i+1 >= len(b) || b[i+1] < 0xa1 || b[i+1] > 0xdf {
+				score -= 2
+				continue
+			}
+			i++
+			score += 3
+		case c == 0x8f:
+			// 補助漢字
+			if i+2 >= len(b) || b[i+1] < 0xa1 || b[i+2] < 0xa1 {
+				score -= 2
+				continue
+			}
+			i += 2
+		case c >= 0xa1 && c <= 0xfe:
+			if i+1 >= len(b) || b[i+1] < 0xa1 || b[i+1] > 0xfe {
+				score -= 2
+				continue
+			}
+			i++
+			switch {
+			case c == 0xa4 || c == 0xa5:
+				// ひらがな・カタカナ
+				score += 3
+			case c == 0xa3 || (c >= 0xb0 && c <= 0xf4):
+				// 全角英数字・漢字。Shift-JIS では半角カナ2文字とも読めるので、同じ重みにする
+				score += 2
+			}
+		default:
+			score -= 2
+		}
+	}
+	return score
+}
+
+// DecodeText 文字コードを判定して UTF-8 の文字列に変換する
+func DecodeText(b []byte) (string, TextEncoding, error) {
+	var dec *encoding.Decoder
+
+	enc := DetectEncoding(b)
+	switch enc {
+	case encASCII, encUTF8:
+		return string(b), enc, nil
+	case encUTF8BOM:
+		return string(b[len(bomUTF8):]), enc, nil
+	case encUTF16LE:
+		dec = unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder()
+	case encUTF16BE:
+		dec = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder()
+	case encShiftJIS:
+		dec = japanese.ShiftJIS.NewDecoder()
+	case encEUCJP:
+		dec = japanese.EUCJP.NewDecoder()
+	}
+	r, err := dec.Bytes(b)
+	if err != nil {
+		return "", enc, err
+	}
+	return string(r), enc, nil
+}
+
+// EncodeText UTF-8 の文字列を指定した文字コードに変換する。表現できない文字は置き換える。
+func EncodeText(s string, enc TextEncoding) ([]byte, error) {
+	var e encoding.Encoding
+
+	switch enc {
+	case encUTF16LE:
+		e = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case encUTF16BE:
+		e = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	case encShiftJIS:
+		e = japanese.ShiftJIS
+	case encEUCJP:
+		e = japanese.EUCJP
+	default:
+		return []byte(s), nil
+	}
+	return encoding.ReplaceUnsupported(e.NewEncoder()).Bytes([]byte(s))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"os"
+	"strings"
+	"testing"
+)
+
+func encode(t *testing.T, e encoding.Encoding, s string) []byte {
+	t.Helper()
+	b, err := e.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDetectEncoding(t *testing.T) {
+	sjis, err := os.ReadFile("radio.m3u.shiftjis.backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		b    []byte
+		want TextEncoding
+	}{
+		{"ascii", []byte("#EXTM3U\n#EXTINF:-1,USA / AFN\n"), encASCII},
+		{"utf8", []byte("#EXTINF:-1,東京 / ＮＨＫ第１\n"), encUTF8},
+		{"utf8 bom", append([]byte{0xef, 0xbb, 0xbf}, "#EXTM3U\n"...), encUTF8BOM},
+		{"utf16le bom", []byte{0xff, 0xfe, '#', 0}, encUTF16LE},
+		{"utf16be bom", []byte{0xfe, 0xff, 0, '#'}, encUTF16BE},
+		{"shift-jis backup", sjis, encShiftJIS},
+		{"shift-jis half width kana", encode(t, japanese.ShiftJIS, "#EXTINF:-1,Saitama / ｽﾏｲﾙﾗｼﾞｵ\n"), encShiftJIS},
+		{"shift-jis full width", encode(t, japanese.ShiftJIS, "#EXTINF:-1,東京 / ＮＨＫ第１\n"), encShiftJIS},
+		{"euc-jp full width", encode(t, japanese.EUCJP, "#EXTINF:-1,Tokyo / ＮＨＫ第１\n"), encEUCJP},
+		{"euc-jp kana", encode(t, japanese.EUCJP, "#EXTINF:-1,東京 / ラジオ日経\n"), encEUCJP},
+		{"euc-jp second level kanji", encode(t, japanese.EUCJP, "#EXTINF:-1,埼玉 / 熊谷\n"), encEUCJP},
+	}
+	for _, tt := range tests {
+		if got := DetectEncoding(tt.b); got != tt.want {
+			t.Errorf("%s: %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeShiftJIS(t *testing.T) {
+	b, err := os.ReadFile("radio.m3u.shiftjis.backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, enc, err := DecodeText(b)
+	if err != nil || enc != encShiftJIS {
+		t.Fatalf("%s %v", enc, err)
+	}
+	if !strings.Contains(s, "ｽﾏｲﾙﾗｼﾞｵ") {
+		t.Errorf("decoded:\n%s", s[:200])
+	}
+	// 書き込みは元の文字コードに戻す
+	if e, err := EncodeText("ｽﾏｲﾙﾗｼﾞｵ", enc); err != nil || !strings.Contains(string(b), string(e)) {
+		t.Errorf("encoded %q %v", e, err)
+	}
+}
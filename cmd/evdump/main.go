@@ -0,0 +1,52 @@
+// evdump は記録した入力デバイスの通知（struct input_event）を読んでキーの入力を表示する
+// 開発用のツール。入力デバイスを直接指定すれば押したキーの名前を調べられる。
+// 記録は cat /dev/input/eventN > rec.bin で取れる（大きさはその環境のもの）。
+//
+//	go run ./cmd/evdump -size 24 testdata/evdev/media24.bin
+//	go run ./cmd/evdump -size 16 testdata/evdev/media16.bin
+package main
+
+import (
+	"flag"
+	"fmt"
+	"local.packages/evdev"
+	"log"
+	"os"
+)
+
+var (
+	size = flag.Int("size", evdev.EventSize, "size of struct input_event (16 or 24)")
+	all  = flag.Bool("all", false, "show all events, not only keys")
+)
+
+func main() {
+	flag.Parse()
+	if *size != 16 && *size != 24 {
+		log.Fatalf("-size %d: 16 か 24", *size)
+	}
+	values := []string{"release", "press", "repeat"}
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ch := make(chan evdev.Event)
+		done := make(chan error, 1)
+		go func() {
+			done <- evdev.ReadEvents(f, *size, ch)
+			close(ch)
+		}()
+		for e := range ch {
+			switch {
+			case e.Type == evdev.EvKey && e.Value >= 0 && int(e.Value) < len(values):
+				fmt.Printf("%s %.6f %s %s\n", path, e.Time.Seconds(), evdev.KeyName(e.Code), values[e.Value])
+			case *all:
+				fmt.Printf("%s %.6f type %d code %d value %d\n", path, e.Time.Seconds(), e.Type, e.Code, e.Value)
+			}
+		}
+		if err := <-done; err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+		f.Close()
+	}
+}
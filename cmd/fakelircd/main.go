@@ -0,0 +1,91 @@
+// fakelircd は lircd の代わりに Unix ソケットでボタンの通知を送る開発用のサーバー。
+// 標準入力の1行ごとに、接続しているクライアント全てへ通知を送る。
+// 行は "ボタン [押し続けた回数] [リモコン]"。止めて起こし直せば再接続を確かめられる。
+//
+//	go run ./cmd/fakelircd -socket /tmp/lircd
+//	KEY_VOLUMEUP
+//	KEY_VOLUMEUP 3
+//	KEY_OK 0 myremote
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	socket = flag.String("socket", "/tmp/lircd", "socket path")
+	remote = flag.String("remote", "fakeremote", "remote name")
+
+	mu      sync.Mutex
+	clients = make(map[net.Conn]bool)
+)
+
+// line lircd の通知の書式に直す
+func line(s string) (string, bool) {
+	f := strings.Fields(s)
+	if len(f) == 0 {
+		return "", false
+	}
+	repeat := 0
+	if len(f) > 1 {
+		n, err := strconv.Atoi(f[1])
+		if err != nil {
+			return "", false
+		}
+		repeat = n
+	}
+	r := *remote
+	if len(f) > 2 {
+		r = f[2]
+	}
+	return fmt.Sprintf("%016x %02x %s %s\n", 0, repeat, f[0], r), true
+}
+
+func main() {
+	flag.Parse()
+	os.Remove(*socket)
+	l, err := net.Listen("unix", *socket)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(*socket)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			mu.Lock()
+			clients[conn] = true
+			mu.Unlock()
+			log.Println("connected")
+		}
+	}()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		s, ok := line(scanner.Text())
+		if !ok {
+			log.Printf("%q: ボタン [回数] [リモコン]", scanner.Text())
+			continue
+		}
+		mu.Lock()
+		for conn := range clients {
+			if _, err := conn.Write([]byte(s)); err != nil {
+				conn.Close()
+				delete(clients, conn)
+			}
+		}
+		mu.Unlock()
+	}
+}
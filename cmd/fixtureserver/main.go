@@ -0,0 +1,24 @@
+// fixtureserver はラジオが参照する外部サービスの代わりに、testdata 以下の
+// fixture を返す開発用のサーバー。設定ファイルの各 URL をこのサーバーに向けて使う。
+//
+//	go run ./cmd/fixtureserver -addr :8080 -dir testdata
+package main
+
+import (
+	"flag"
+	"github.com/sakaisatoru/go_radio_br_zero/fixture"
+	"log"
+	"net/http"
+)
+
+var (
+	addr = flag.String("addr", ":8080", "listen address")
+	dir  = flag.String("dir", "testdata", "fixture directory")
+)
+
+func main() {
+	flag.Parse()
+
+	log.Printf("fixture server %s (%s)", *addr, *dir)
+	log.Fatal(http.ListenAndServe(*addr, fixture.Handler(*dir)))
+}
@@ -0,0 +1,35 @@
+// irdecode は記録した赤外線リモコンのパルス列を解読して表示する開発用のツール。
+// 受光モジュール無しで irremote の解読を確かめられる。
+//
+//	go run ./cmd/irdecode testdata/ir/nec.txt testdata/ir/rc5.txt
+package main
+
+import (
+	"flag"
+	"fmt"
+	"local.packages/irremote"
+	"log"
+	"os"
+)
+
+func main() {
+	flag.Parse()
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pulses, err := irremote.ReadPulses(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+
+		dec := irremote.New()
+		for _, p := range pulses {
+			if c, ok := dec.Feed(p.Mark, p.Duration); ok {
+				fmt.Printf("%s: %s\n", path, c)
+			}
+		}
+	}
+}
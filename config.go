@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	configFile string = "/home/sakai/program/radio.json"
+)
+
+// DirectoryQuery 局検索の条件。LCD上でラベルを選んで検索する。
+type DirectoryQuery struct {
+	Label   string `json:"label"`
+	Tag     string `json:"tag,omitempty"`
+	Country string `json:"countrycode,omitempty"`
+	Name    string `json:"name,omitempty"`
+}
+
+// AccelStep 回す速さに応じた1刻みあたりの移動量。Within（ミリ秒）より短い間隔で回すと Steps 進める。
+type AccelStep struct {
+	Within int `json:"within"`
+	Steps  int `json:"steps"`
+}
+
+// IdleTimeouts 状態の名前 -> 入力が無ければ入力待ち（ラジオが鳴っていれば音量調整）へ戻るまでの時間（秒）。
+// 0 あるいは記述の無い状態では戻らない。
+type IdleTimeouts map[string]int
+
+// AccelCurves 状態ごとの加速の設定。Within の短い順に並べる。空であれば加速しない。
+type AccelCurves struct {
+	Station  []AccelStep `json:"station"`   // 選局
+	AlarmMin []AccelStep `json:"alarm_min"` // アラームの分
+	Volume   []AccelStep `json:"volume"`    // 音量
+}
+
+type Config struct {
+	RadioBrowserURL     string           `json:"radiobrowser_url"`
+	DirectoryQueries    []DirectoryQuery `json:"directory_queries"`
+	HealthCheckInterval int              `json:"health_check_interval"` // 局の死活確認の間隔（分） 0 で確認しない
+	HealthCheckTimeout  int              `json:"health_check_timeout"`  // 死活確認の応答待ち（秒）
+	SkipDeadStations    bool             `json:"skip_dead_stations"`    // 選局時に応答の無い局を飛ばす
+	NHKConfigURL        string           `json:"nhk_config_url"`        // らじる★らじるの設定XML
+	PodcastCacheDir     string           `json:"podcast_cache_dir"`     // ポッドキャストのフィードと再生済みの記録
+	LibraryPlaylist     string           `json:"library_playlist"`      // 手元の音声ファイルを再生する時に書くプレイリスト
+	SeekStep            int              `json:"seek_step"`             // オンデマンド再生時の1刻みのシーク（秒）
+	TuneTimeout         int              `json:"tune_timeout"`          // 選局で再生するURLを求める際の制限時間（秒）
+	TuneRetryLimit      int              `json:"tune_retry_limit"`      // 全てのURLで再生できなかった時に選局をやり直す回数
+	TuneRetryWait       int              `json:"tune_retry_wait"`       // やり直すまでの待ち時間（秒） やり直す毎に倍にする
+	StallTimeout        int              `json:"stall_timeout"`         // 配信が途切れたとみなすまでの時間（秒） 0 で再接続しない
+	Acceleration        AccelCurves      `json:"acceleration"`          // ロータリーエンコーダの加速
+	InputMode           string           `json:"input_mode"`            // "edge" 端子の変化を待つ、"poll" 一定間隔で読む
+	GPIOChip            string           `json:"gpio_chip"`             // エッジ検出に使う GPIO キャラクタデバイス
+	DoubleClickWindow   int              `json:"double_click_window"`   // ダブルクリックとみなす間隔（ミリ秒） 0 で使わない
+	Keymap              KeymapConfig     `json:"keymap"`                // 状態ごとの入力と操作の割り当て（既定の割り当てに重ねる）
+	IRPin               int              `json:"ir_pin"`                // 赤外線受光モジュールを繋いだ GPIO 0 で使わない
+	IRKeymapFile        string           `json:"ir_keymap_file"`        // 学習したリモコンのボタンと操作の割り当て
+	LircSocket          string           `json:"lirc_socket"`           // lircd のソケット 空なら使わない
+	LircKeymap          RemoteKeymap     `json:"lirc_keymap"`           // lircd のボタン -> 入力あるいは操作の名前
+	EvdevDevices        string           `json:"evdev_devices"`         // キーを読む入力デバイス（glob） 空なら使わない
+	EvdevGrab           bool             `json:"evdev_grab"`            // 入力デバイスのキーを他のプログラムへ渡さない
+	EvdevKeymap         RemoteKeymap     `json:"evdev_keymap"`          // 入力デバイスのキー -> 入力あるいは操作の名前
+	JournalFile         string           `json:"journal_file"`          // 入力と状態の遷移の記録 空なら記録しない
+	JournalMaxSize      int              `json:"journal_max_size"`      // 記録を新しいファイルに切り替える大きさ（KB）
+	JournalBackups      int              `json:"journal_backups"`       // 残しておく古い記録の数
+	BacklightDuration   int              `json:"backlight_duration"`    // 操作してからバックライトを消すまでの時間（秒）
+	SleepDuration       int              `json:"sleep_duration"`        // スリープで止めるまでの時間（分）
+	VolumeLimit         int              `json:"volume_limit"`          // 音量の上限 0 で制限しない
+	Timezone            string           `json:"timezone"`              // 時計とアラームに使うタイムゾーン
+	AlarmTime           string           `json:"alarm_time"`            // アラームの時刻 "HH:MM"
+	StationSort         string           `json:"station_sort"`          // 局の並び "file" 局リストの順、"name" 局名、"group" グループと局名
+	IdleTimeouts        IdleTimeouts     `json:"idle_timeouts"`         // 入力が無ければ戻る状態と時間（既定の値に重ねる）
+	StationURLs         map[string]int   `json:"station_urls"`          // 予備のURLで再生できた局（先頭のURL -> 何番目のURLか）
+}
+
+var (
+	config = ConfigDefault()
+)
+
+// ConfigDefault 設定ファイルが無い場合の既定値を返す
+func ConfigDefault() *Config {
+	return &Config{
+		RadioBrowserURL: "https://de1.api.radio-browser.info",
+		DirectoryQueries: []DirectoryQuery{
+			{Label: "Japan", Country: "JP"},
+			{Label: "Jazz", Tag: "jazz"},
+			{Label: "Classic", Tag: "classical"},
+			{Label: "Lounge", Tag: "lounge"},
+			{Label: "Anime", Tag: "anime"},
+		},
+		HealthCheckInterval: 60,
+		HealthCheckTimeout:  5,
+		SkipDeadStations:    false,
+		NHKConfigURL:        "https://www.nhk.or.jp/radio/config/config_web.xml",
+		PodcastCacheDir:     "/home/sakai/program/podcast",
+		LibraryPlaylist:     "/tmp/radio_library.m3u",
+		SeekStep:            30,
+		TuneTimeout:         20,
+		TuneRetryLimit:      5,
+		TuneRetryWait:       2,
+		StallTimeout:        15,
+		Acceleration: AccelCurves{
+			Station:  []AccelStep{{Within: 40, Steps: 5}, {Within: 90, Steps: 2}},
+			AlarmMin: []AccelStep{{Within: 40, Steps: 10}, {Within: 90, Steps: 5}},
+			Volume:   []AccelStep{{Within: 40, Steps: 2}},
+		},
+		InputMode:         inputModeEdge,
+		GPIOChip:          "/dev/gpiochip0",
+		DoubleClickWindow: 300,
+		IRKeymapFile:      "/home/sakai/program/ir_keymap.json",
+		LircKeymap: RemoteKeymap{
+			"KEY_UP":         "forward",
+			"KEY_DOWN":       "backward",
+			"KEY_OK":         "click",
+			"KEY_BACK":       "long",
+			"KEY_VOLUMEUP":   "volup",
+			"KEY_VOLUMEDOWN": "voldown",
+			"KEY_MUTE":       "mute",
+			"KEY_NEXT":       "next",
+			"KEY_PREVIOUS":   "prev",
+			"KEY_POWER":      "off",
+		},
+		EvdevDevices:   "/dev/input/event*",
+		JournalMaxSize: 256,
+		JournalBackups: 3,
+		EvdevKeymap: RemoteKeymap{
+			"KEY_UP":           "forward",
+			"KEY_DOWN":         "backward",
+			"KEY_ENTER":        "click",
+			"KEY_ESC":          "long",
+			"KEY_VOLUMEUP":     "volup",
+			"KEY_VOLUMEDOWN":   "voldown",
+			"KEY_MUTE":         "mute",
+			"KEY_NEXTSONG":     "next",
+			"KEY_PREVIOUSSONG": "prev",
+			"KEY_PLAYPAUSE":    "toggle",
+			"KEY_STOPCD":       "off",
+			"KEY_1":            "preset 1",
+			"KEY_2":            "preset 2",
+			"KEY_3":            "preset 3",
+			"KEY_4":            "preset 4",
+			"KEY_5":            "preset 5",
+			"KEY_6":            "preset 6",
+			"KEY_7":            "preset 7",
+			"KEY_8":            "preset 8",
+			"KEY_9":            "preset 9",
+		},
+		BacklightDuration: 20,
+		SleepDuration:     30,
+		Timezone:          "Asia/Tokyo",
+		AlarmTime:         "04:50",
+		StationSort:       stationSortFile,
+		IdleTimeouts: IdleTimeouts{
+			"tune":        30,
+			"function":    30,
+			"alarm_hour":  30,
+			"alarm_min":   30,
+			"dir_query":   60,
+			"dir_browse":  120,
+			"episode":     60,
+			"diagnostics": 60,
+			"ir_learn":    120,
+			"settings":    60,
+			"history":     30,
+		},
+	}
+}
+
+// LoadConfig 設定ファイルを読み込む。ファイルが無ければ既定値を返す。
+// ファイルに記述の無い項目は既定値のままとなる。
+func LoadConfig(path string) (*Config, error) {
+	c := ConfigDefault()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c, nil
+		}
+		return c, err
+	}
+	// 配列は既定値の要素に重ねて読まれるので、書かれていれば既定値を捨ててから読む
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return ConfigDefault(), err
+	}
+	if _, ok := keys["directory_queries"]; ok {
+		c.DirectoryQueries = nil
+	}
+	if err := json.Unmarshal(b, c); err != nil {
+		return ConfigDefault(), err
+	}
+	return c, nil
+}
+
+// UpdateConfigFile 設定ファイルの keys の項目だけを c の値で書き換える。
+// 他の項目は書かれたままとし、書かれていない項目は既定値のままとする。
+func UpdateConfigFile(path string, c *Config, keys ...string) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(b, &values); err != nil {
+		return err
+	}
+
+	file := make(map[string]json.RawMessage)
+	b, err = os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(b, &file); err != nil {
+			return err
+		}
+	}
+	for _, k := range keys {
+		v, ok := values[k]
+		if !ok {
+			return fmt.Errorf("%s: 設定の項目にありません", k)
+		}
+		file[k] = v
+	}
+
+	b, err = json.MarshalIndent(file, "", "\t")
+	if err != nil {
+		return err
+	}
+	// 書き込みの途中で電源が切れても壊れないよう、別のファイルに書いてから置き換える
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
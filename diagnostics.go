@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+)
+
+// diagPage 診断画面の1頁。1行目に項目名、2行目に回数を表示する。
+type diagPage struct {
+	label string
+	count func() int
+}
+
+var (
+	diagPages = []diagPage{
+		{"total", func() int { return tuneDiag.Total() }},
+		{"retry", func() int { return tuneDiag.Retries }},
+		{"giveup", func() int { return tuneDiag.GiveUps }},
+		{tuneErrDNS.String(), func() int { return tuneDiag.Errors[tuneErrDNS] }},
+		{tuneErrHTTPClient.String(), func() int { return tuneDiag.Errors[tuneErrHTTPClient] }},
+		{tuneErrHTTPServer.String(), func() int { return tuneDiag.Errors[tuneErrHTTPServer] }},
+		{tuneErrAuth.String(), func() int { return tuneDiag.Errors[tuneErrAuth] }},
+		{tuneErrTimeout.String(), func() int { return tuneDiag.Errors[tuneErrTimeout] }},
+		{tuneErrNoAudio.String(), func() int { return tuneDiag.Errors[tuneErrNoAudio] }},
+		{tuneErrStall.String(), func() int { return tuneDiag.Errors[tuneErrStall] }},
+		{tuneErrUnknown.String(), func() int { return tuneDiag.Errors[tuneErrUnknown] }},
+	}
+)
+
+// diagStateString 診断画面の2行目（回数）を返す
+func (v *RadioState) diagStateString() string {
+	return fmt.Sprintf("%8d", diagPages[v.diagPos].count())
+}
+
+// nextDiagPage 診断画面の頁を d だけ動かす（端で折り返す）
+func (v *RadioState) nextDiagPage(d int) {
+	v.diagPos = (v.diagPos + d + len(diagPages)) % len(diagPages)
+	infomation.Update(0, diagPages[v.diagPos].label)
+}
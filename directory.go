@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// dirStateString 局検索中の2行目（位置/件数）を返す
+func (v *RadioState) dirStateString() string {
+	if v.currState == stateDirQuery {
+		return fmt.Sprintf("find%3d ", v.dirQueryPos+1)
+	}
+	return fmt.Sprintf("%3d/%-4d", v.dirPos+1, len(v.dirResults))
+}
+
+// showDirResult 検索結果の局名を表示する
+func (v *RadioState) showDirResult() {
+	st := &v.dirResults[v.dirPos]
+	infomation.Update(0, st.Name+" ("+st.CountryCode+" "+st.Codec+")")
+}
+
+// nextDirQuery 検索条件を d だけ動かす
+func (v *RadioState) nextDirQuery(d int) {
+	v.dirQueryPos = min(max(v.dirQueryPos+d, 0), len(config.DirectoryQueries)-1)
+	infomation.Update(0, config.DirectoryQueries[v.dirQueryPos].Label)
+}
+
+// nextDirResult 検索結果を d だけ動かす
+func (v *RadioState) nextDirResult(d int) {
+	v.dirPos = min(max(v.dirPos+d, 0), len(v.dirResults)-1)
+	v.showDirResult()
+}
+
+// findStations 選んだ条件で局を裏で検索する。見つかれば !found で検索結果の閲覧へ移り、
+// 見つからなければ条件の選択に留まる
+func (v *RadioState) findStations() {
+	infomation.Update(0, "ｹﾝｻｸﾁｭｳ")
+	rb := RadioBrowserNew(config.RadioBrowserURL)
+	pos := v.dirQueryPos
+	q := config.DirectoryQueries[pos]
+	fetch(radioBrowserTimeout, func(ctx context.Context) func() {
+		st, err := rb.Search(ctx, q)
+		return func() {
+			if v.currState != stateDirQuery || v.dirQueryPos != pos {
+				// 検索中に条件を変えたか、検索をやめた
+				return
+			}
+			v.foundStations(st, err)
+		}
+	})
+}
+
+// foundStations 検索の結果を表示する
+func (v *RadioState) foundStations(st []RadioBrowserStation, err error) {
+	if err != nil {
+		log.Println(err)
+		infomation.ShowError(ErrorSearch)
+		return
+	}
+	if len(st) == 0 {
+		infomation.Update(0, "ﾐﾂｶﾘﾏｾﾝ")
+		return
+	}
+	v.dirResults = st
+	v.dirPos = 0
+	v.Event(eventFound)
+}
+
+// addDirResult 表示中の検索結果を局リストへ加える
+func (v *RadioState) addDirResult() {
+	st := &v.dirResults[v.dirPos]
+	group := st.CountryCode
+	if group == "" {
+		group = "radio-browser"
+	}
+	if err := v.AddStation(group, st.Name, st.StreamURL()); err != nil {
+		log.Println(err)
+		infomation.ShowError(ErrorHup)
+		return
+	}
+	infomation.Update(0, "ﾂｲｶｼﾏｼﾀ")
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/sakaisatoru/go_radio_br_zero/fixture"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// radioBrowserFixture cmd/fixtureserver と同じく testdata の局を返す radio-browser.info の代わり。
+// status が 200 以外ならそのまま返す
+func radioBrowserFixture(t *testing.T, status int) *httptest.Server {
+	t.Helper()
+	h := fixture.Handler("testdata")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		if r.URL.Path == "/json/stations/search" {
+			if q := r.URL.Query(); q.Get("hidebroken") != "true" || q.Get("limit") != fmt.Sprint(radioBrowserLimit) {
+				t.Errorf("query %s", r.URL.RawQuery)
+			}
+		}
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// setGlobal テストの間だけパッケージの変数を v に置き換え、終わったら元に戻す
+func setGlobal[T any](t *testing.T, p *T, v T) {
+	t.Helper()
+	saved := *p
+	*p = v
+	t.Cleanup(func() { *p = saved })
+}
+
+// testRadio 偽物の画面と mpv の上に設定と局リストを用意する。局リストは書き換えてよい様に複製する
+func testRadio(t *testing.T, conf string) *replayer {
+	t.Helper()
+	dir := t.TempDir()
+	b, err := os.ReadFile("testdata/scenario/stations.m3u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stations := filepath.Join(dir, "stations.m3u")
+	if err := os.WriteFile(stations, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	confPath := filepath.Join(dir, "radio.json")
+	if err := os.WriteFile(confPath, []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := &replayer{out: io.Discard, scr: screenLCDNew(), clock: time.Date(2026, time.July, 6, 12, 0, 0, 0, jst)}
+	if err := r.setup(confPath, stations); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRadioBrowserSearch(t *testing.T) {
+	srv := radioBrowserFixture(t, http.StatusOK)
+	st, err := RadioBrowserNew(srv.URL+"/").Search(context.Background(), DirectoryQuery{Tag: "jazz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range st {
+		names = append(names, s.Name)
+	}
+	want := []string{"Smooth Jazz Florida", "Klassik Radio Dinner Jazz"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("names %q, want %q", names, want)
+	}
+	if u := st[0].StreamURL(); u != "http://smoothjazzflorida.example/stream" {
+		t.Errorf("StreamURL %s", u)
+	}
+}
+
+func TestRadioBrowserSearchStatus(t *testing.T) {
+	srv := radioBrowserFixture(t, http.StatusServiceUnavailable)
+	if _, err := RadioBrowserNew(srv.URL).Search(context.Background(), DirectoryQuery{Tag: "jazz"}); err == nil {
+		t.Error("no error for 503")
+	}
+}
+
+// 検索はループを止めずに裏で行い、結果が出たら閲覧へ移る。選んだ局は局リストに加える
+func TestFindStations(t *testing.T) {
+	srv := radioBrowserFixture(t, http.StatusOK)
+	r := testRadio(t, `{"radiobrowser_url": "`+srv.URL+`", "directory_queries": [{"label": "Jazz", "tag": "jazz"}]}`)
+
+	r.input(JournalEntry{Kind: journalAction, Action: "search"})
+	if s := radioState.GetState(); s != stateDirQuery {
+		t.Fatalf("state %s", stateName(s))
+	}
+	radioState.findStations()
+	if radioState.GetState() != stateDirQuery || !fetchPending {
+		t.Fatal("search did not run in the background")
+	}
+	r.settle()
+	if s := radioState.GetState(); s != stateDirBrowse {
+		t.Fatalf("state %s after search", stateName(s))
+	}
+	if n := len(radioState.dirResults); n != 2 {
+		t.Fatalf("%d results", n)
+	}
+
+	n := radioState.stationListLen
+	r.input(JournalEntry{Kind: journalAction, Action: "add"})
+	if radioState.stationListLen != n+1 {
+		t.Fatalf("stations %d, want %d", radioState.stationListLen, n+1)
+	}
+	b, err := os.ReadFile(radioState.stationPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "#EXTINF:-1,US / Smooth Jazz Florida\nhttp://smoothjazzflorida.example/stream\n") {
+		t.Errorf("station list:\n%s", b)
+	}
+}
+
+func TestFindStationsError(t *testing.T) {
+	srv := radioBrowserFixture(t, http.StatusInternalServerError)
+	r := testRadio(t, `{"radiobrowser_url": "`+srv.URL+`", "directory_queries": [{"label": "Jazz", "tag": "jazz"}]}`)
+
+	r.input(JournalEntry{Kind: journalAction, Action: "search"})
+	r.input(JournalEntry{Kind: journalAction, Action: "find"})
+	if s := radioState.GetState(); s != stateDirQuery {
+		t.Fatalf("state %s", stateName(s))
+	}
+	if got := strings.TrimSpace(r.scr.Line(0)); got != "ｹﾝｻｸｴﾗｰ" {
+		t.Errorf("line0 %q", got)
+	}
+}
+
+// 検索中に条件の選択を出たら結果は捨てる
+func TestFindStationsCancel(t *testing.T) {
+	srv := radioBrowserFixture(t, http.StatusOK)
+	r := testRadio(t, `{"radiobrowser_url": "`+srv.URL+`", "directory_queries": [{"label": "Jazz", "tag": "jazz"}]}`)
+
+	r.input(JournalEntry{Kind: journalAction, Action: "search"})
+	radioState.findStations()
+	r.input(JournalEntry{Kind: journalAction, Action: "home"})
+	if fetchPending {
+		t.Error("search not cancelled")
+	}
+	select {
+	case res := <-fetchDone:
+		fetchCompleted(res)
+	case <-time.After(5 * time.Second):
+		t.Fatal("search did not finish")
+	}
+	if s := radioState.GetState(); s != stateNormalMode {
+		t.Errorf("state %s", stateName(s))
+	}
+	if radioState.dirResults != nil {
+		t.Error("results kept after leaving")
+	}
+}
+
+func TestStationEntry(t *testing.T) {
+	entry := StationEntry("US/CA", "Evil\r\n#EXTINF:-1,X / Y\nhttp://evil/ / AC/DC", "http://a.example/s\nhttp://b.example/\n")
+	st := ParseStationList("#EXTM3U\n"+entry, 50)
+	if len(st) != 1 {
+		t.Fatalf("%d stations from %q", len(st), entry)
+	}
+	if st[0].Group != "US-CA" {
+		t.Errorf("group %q", st[0].Group)
+	}
+	if name := strings.TrimSpace(st[0].Name); name != "Evil  #EXTINF:-1,X - Y http:--evil- - AC-DC" {
+		t.Errorf("name %q", name)
+	}
+	// 複数行のURLは先頭の行だけを使う
+	if st[0].Url != "http://a.example/s" {
+		t.Errorf("url %q", st[0].Url)
+	}
+}
+
+// 設定ファイルの検索条件は既定の条件に重ねずに置き換える
+func TestLoadConfigQueries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "radio.json")
+	if err := os.WriteFile(path, []byte(`{"directory_queries": [{"label": "Jazz", "tag": "jazz"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []DirectoryQuery{{Label: "Jazz", Tag: "jazz"}}; fmt.Sprint(c.DirectoryQueries) != fmt.Sprint(want) {
+		t.Errorf("queries %+v, want %+v", c.DirectoryQueries, want)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 )
@@ -9,12 +11,26 @@ var (
 	displayWeekday = [...]string{"Su", "Mo", "Tu", "We", "Th", "Fr", "Sa"}
 )
 
+// LCD 表示器（aqm0802a）。記録の再生では画面の内容を覚えておくだけのものに差し替える
+type LCD interface {
+	Init()
+	DisplayOff()
+	LightOff()
+	OneShotLight()
+	SetLightDuration(t time.Duration)
+	PrintWithPos(x uint8, y uint8, s []byte)
+	UTF8toOLED(m string) ([]byte, int)
+}
+
 type InfomationDisplay struct {
-	mu       sync.Mutex
-	buff     []byte
-	buffPos  int
-	buffLen  int
-	isScroll bool // 自動スクロール（デフォルトで有効）
+	mu        sync.Mutex
+	buff      []byte
+	buffPos   int
+	buffLen   int
+	isScroll  bool // 自動スクロール（デフォルトで有効）
+	tuneFrame int  // 選局中表示のコマ
+	errMsg    []byte
+	errUntil  time.Time // 選局エラーを表示しておく期限
 }
 
 func InfomationDisplayNew() *InfomationDisplay {
@@ -52,11 +68,39 @@ func (v *InfomationDisplay) Update(line int, s string) {
 
 // ShowError エラーメッセージを表示する。
 func (v *InfomationDisplay) ShowError(e int) {
-	v.buff = []byte(errmessage[e])
+	v.buff = errorText(e)
 	v.buffLen = len(v.buff)
 	lcd.PrintWithPos(0, 0, v.buff[:8])
 }
 
+// ShowTuneError 選局のエラーを一定時間1行目に表示する。
+// バッファの内容は残すので、期限が過ぎれば局名等の表示に戻る。
+func (v *InfomationDisplay) ShowTuneError(k TuneErrorKind, d time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	v.errMsg = errorText(k.Message())
+	v.errUntil = timeNow().Add(d)
+	lcd.PrintWithPos(0, 0, v.errMsg)
+}
+
+// ClearError 選局のエラーの表示をやめる。
+func (v *InfomationDisplay) ClearError() {
+	v.errUntil = time.Time{}
+}
+
+// HasError 選局のエラーを表示中かどうかを返す。
+func (v *InfomationDisplay) HasError() bool {
+	return timeNow().Before(v.errUntil)
+}
+
+// errorText エラーメッセージを LCD のコードで8文字に揃えて返す。
+func errorText(e int) []byte {
+	r, l := lcd.UTF8toOLED(errmessage[e])
+	t := append(r[:l:l], "        "...)
+	return t[:8]
+}
+
 // ShowClock 時計を表示する。バッファされている文字列があれば1行目に表示する。
 func (v *InfomationDisplay) ShowClock(alarmflags string) {
 	var c, dt string
@@ -67,19 +111,34 @@ func (v *InfomationDisplay) ShowClock(alarmflags string) {
 	if len(alarmflags) > 2 {
 		// フラグ以外のもの（アラーム時刻等）が含まれていればそのまま表示して終わる。
 		lcd.PrintWithPos(0, 1, []byte(alarmflags))
+		if radioState.IsBrowsing() || radioState.GetState() == statePlayback || radioState.IsTrackSkip() {
+			// 局検索中や番組の再生中、曲送り中は1行目をスクロールさせる
+			v.scrollBuffer()
+		}
 		return
 	}
 
-	n := time.Now().In(jst) //Local()
+	n := timeNow().In(jst) //Local()
 	if colon == 0 {
 		c = " "
 	} else {
 		c = ":"
 	}
 
-	alarmflags = alarmflags + " " + n.Format("15") + c + n.Format("04")
+	sep := " "
+	if radioState.IsMuted() {
+		// 消音中
+		sep = "m"
+	}
+	alarmflags = alarmflags + sep + n.Format("15") + c + n.Format("04")
 	lcd.PrintWithPos(0, 1, []byte(alarmflags))
 
+	if v.HasError() {
+		// 選局のエラーはラジオが切られていても表示しておく
+		lcd.PrintWithPos(0, 0, v.errMsg)
+		return
+	}
+
 	if !radioState.IsRadioEnable() {
 		// ラジオが切られていたら日付を表示して終わる
 		dt = n.Format("01-02") + " " + displayWeekday[n.Weekday()]
@@ -87,6 +146,22 @@ func (v *InfomationDisplay) ShowClock(alarmflags string) {
 		return
 	}
 
+	if radioState.IsTuning() {
+		// 選局中は動きのある表示にする
+		s := "tuning"
+		if radioState.IsReconnecting() {
+			s = "reconn"
+		}
+		v.tuneFrame = (v.tuneFrame + 1) % 3
+		lcd.PrintWithPos(0, 0, []byte(fmt.Sprintf("%-8s", s+strings.Repeat(".", v.tuneFrame))))
+		return
+	}
+
+	v.scrollBuffer()
+}
+
+// scrollBuffer バッファされている文字列を1行目に表示する。長ければスクロールさせる。
+func (v *InfomationDisplay) scrollBuffer() {
 	if v.isScroll && v.buffLen > 8 {
 		lcd.PrintWithPos(0, 0, v.buff[v.buffPos:v.buffPos+8])
 		v.buffPos++
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"local.packages/evdev"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	evdevRescanInterval time.Duration = 3 * time.Second // 入力デバイスの抜き差しを調べる間隔
+)
+
+// startEvdevInput 入力デバイス（USB キーボードやメディアキーのリモコン）のキーを
+// evdev_keymap で入力あるいは操作に直して送る。evdev_devices が空なら何もしない。
+func startEvdevInput(code chan<- ButtonCode, remote chan<- RemoteAction) {
+	if config.EvdevDevices == "" {
+		return
+	}
+	ev := make(chan evdev.Event)
+	go evdevScanLoop(config.EvdevDevices, config.EvdevGrab, ev)
+	go evdevKeys(ev, config.EvdevKeymap, code, remote)
+}
+
+// evdevKeys キーを押した・押し続けている通知を割り当てに従って送る
+func evdevKeys(ev <-chan evdev.Event, m RemoteKeymap, code chan<- ButtonCode, remote chan<- RemoteAction) {
+	for e := range ev {
+		if e.Type != evdev.EvKey || e.Value == evdev.KeyRelease {
+			continue
+		}
+		if a, ok := m[evdev.KeyName(e.Code)]; ok {
+			sendRemote(a, e.Value == evdev.KeyRepeat, code, remote)
+		}
+	}
+}
+
+// evdevScanLoop pattern に合う入力デバイスを定期的に探し、新しく見つかったものを読み始める。
+// 外されたデバイスは読み終えて、次に差された時に開き直す。
+func evdevScanLoop(pattern string, grab bool, ev chan<- evdev.Event) {
+	var mu sync.Mutex
+	opened := make(map[string]bool)
+
+	for {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			log.Printf("evdev_devices: %v", err)
+			return
+		}
+		for _, p := range paths {
+			mu.Lock()
+			busy := opened[p]
+			mu.Unlock()
+			if busy {
+				continue
+			}
+			d, err := evdev.Open(p, grab)
+			if err != nil {
+				continue
+			}
+			log.Printf("%s: %s を読みます", p, d.Name)
+			mu.Lock()
+			opened[p] = true
+			mu.Unlock()
+			go func() {
+				err := d.Loop(ev)
+				d.Close()
+				log.Printf("%s: %v", p, err)
+				mu.Lock()
+				delete(opened, p)
+				mu.Unlock()
+			}()
+		}
+		time.Sleep(evdevRescanInterval)
+	}
+}
@@ -0,0 +1,120 @@
+// evdev Linux の入力デバイス（/dev/input/event*）から USB キーボードやリモコンの
+// キーの入力を読む
+package evdev
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	EvKey uint16 = 0x01 // キーの入力
+
+	KeyRelease int32 = 0 // 離した
+	KeyPress   int32 = 1 // 押した
+	KeyRepeat  int32 = 2 // 押し続けている（オートリピート）
+
+	ioctlGetName = 0x81004506 // EVIOCGNAME(256)
+	ioctlGrab    = 0x40044590 // EVIOCGRAB
+)
+
+// EventSize この環境の struct input_event の大きさ。struct timeval の大きさで
+// 64bit 環境では 24、32bit 環境では 16 となる。
+var EventSize = 2*int(unsafe.Sizeof(uintptr(0))) + 8
+
+// Event 入力デバイスの通知（struct input_event）
+type Event struct {
+	Time  time.Duration // 通知の時刻
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// Decode size バイトの struct input_event を解く
+func Decode(b []byte, size int) Event {
+	var t time.Duration
+	if size == 24 {
+		t = time.Duration(binary.NativeEndian.Uint64(b[0:]))*time.Second +
+			time.Duration(binary.NativeEndian.Uint64(b[8:]))*time.Microsecond
+	} else {
+		t = time.Duration(binary.NativeEndian.Uint32(b[0:]))*time.Second +
+			time.Duration(binary.NativeEndian.Uint32(b[4:]))*time.Microsecond
+	}
+	b = b[size-8:]
+	return Event{
+		Time:  t,
+		Type:  binary.NativeEndian.Uint16(b[0:]),
+		Code:  binary.NativeEndian.Uint16(b[2:]),
+		Value: int32(binary.NativeEndian.Uint32(b[4:])),
+	}
+}
+
+// ReadEvents r から size バイトずつ通知を読んで ch へ送る。終わりに達するかエラーで戻る。
+// 記録したファイルも入力デバイスも同じように読める。
+func ReadEvents(r io.Reader, size int, ch chan<- Event) error {
+	br := bufio.NewReaderSize(r, size*64)
+	b := make([]byte, size)
+	for {
+		if _, err := io.ReadFull(br, b); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		ch <- Decode(b, size)
+	}
+}
+
+// Device 入力デバイス
+type Device struct {
+	f    *os.File
+	Path string
+	Name string // デバイスの名前
+}
+
+// Open 入力デバイスを開く。grab が true なら他のプログラム（コンソール等）へ入力を渡さない。
+func Open(path string, grab bool) (*Device, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	d := &Device{f: f, Path: path}
+	name := make([]byte, 256)
+	if ioctl(f.Fd(), ioctlGetName, unsafe.Pointer(&name[0])) == nil {
+		d.Name = string(bytes.TrimRight(name, "\x00"))
+	}
+	if grab {
+		if _, _, e := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctlGrab, 1); e != 0 {
+			f.Close()
+			return nil, e
+		}
+	}
+	return d, nil
+}
+
+// Loop 通知を ch へ送り続ける。デバイスが外されるか Close されるとエラーを返して終わる。
+func (d *Device) Loop(ch chan<- Event) error {
+	if err := ReadEvents(d.f, EventSize, ch); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// Close デバイスを閉じる
+func (d *Device) Close() error {
+	return d.f.Close()
+}
+
+func ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	if e != 0 {
+		return e
+	}
+	return nil
+}
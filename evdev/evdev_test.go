@@ -0,0 +1,56 @@
+package evdev
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// readKeys 記録した通知からキーの通知だけを "キー 値" にして返す
+func readKeys(t *testing.T, path string, size int) ([]string, []Event) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	ch := make(chan Event, 64)
+	if err := ReadEvents(f, size, ch); err != nil {
+		t.Fatal(err)
+	}
+	close(ch)
+	var keys []string
+	var all []Event
+	for e := range ch {
+		all = append(all, e)
+		if e.Type == EvKey {
+			keys = append(keys, fmt.Sprintf("%s %d", KeyName(e.Code), e.Value))
+		}
+	}
+	return keys, all
+}
+
+// 32bit と 64bit の環境で記録した通知（リポジトリの testdata/evdev）を読む
+func TestReadEvents(t *testing.T) {
+	want := []string{
+		"KEY_VOLUMEUP 1", "KEY_VOLUMEUP 2", "KEY_VOLUMEUP 2", "KEY_VOLUMEUP 0",
+		"KEY_NEXTSONG 1", "KEY_NEXTSONG 0",
+		"KEY_PLAYPAUSE 1", "KEY_PLAYPAUSE 0",
+		"KEY_1 1", "KEY_1 0",
+	}
+	keys16, ev16 := readKeys(t, "../testdata/evdev/media16.bin", 16)
+	keys24, ev24 := readKeys(t, "../testdata/evdev/media24.bin", 24)
+	for _, got := range [][]string{keys16, keys24} {
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("keys %q, want %q", got, want)
+		}
+	}
+	if len(ev16) != len(ev24) {
+		t.Fatalf("%d events in 16 byte layout, %d in 24", len(ev16), len(ev24))
+	}
+	for i := range ev16 {
+		if ev16[i] != ev24[i] {
+			t.Errorf("event %d: %+v, %+v", i, ev16[i], ev24[i])
+		}
+	}
+}
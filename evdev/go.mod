@@ -0,0 +1,3 @@
+module github.com/sakaisatoru/go_radio_br_zero/evdev
+
+go 1.25.5
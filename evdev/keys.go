@@ -0,0 +1,68 @@
+package evdev
+
+import "fmt"
+
+// keyNames リモコンやキーボードで使いそうなキーの名前（linux/input-event-codes.h）
+var keyNames = map[uint16]string{
+	1:   "KEY_ESC",
+	2:   "KEY_1",
+	3:   "KEY_2",
+	4:   "KEY_3",
+	5:   "KEY_4",
+	6:   "KEY_5",
+	7:   "KEY_6",
+	8:   "KEY_7",
+	9:   "KEY_8",
+	10:  "KEY_9",
+	11:  "KEY_0",
+	14:  "KEY_BACKSPACE",
+	15:  "KEY_TAB",
+	28:  "KEY_ENTER",
+	57:  "KEY_SPACE",
+	102: "KEY_HOME",
+	103: "KEY_UP",
+	104: "KEY_PAGEUP",
+	105: "KEY_LEFT",
+	106: "KEY_RIGHT",
+	107: "KEY_END",
+	108: "KEY_DOWN",
+	109: "KEY_PAGEDOWN",
+	113: "KEY_MUTE",
+	114: "KEY_VOLUMEDOWN",
+	115: "KEY_VOLUMEUP",
+	116: "KEY_POWER",
+	119: "KEY_PAUSE",
+	127: "KEY_COMPOSE",
+	139: "KEY_MENU",
+	142: "KEY_SLEEP",
+	158: "KEY_BACK",
+	159: "KEY_FORWARD",
+	163: "KEY_NEXTSONG",
+	164: "KEY_PLAYPAUSE",
+	165: "KEY_PREVIOUSSONG",
+	166: "KEY_STOPCD",
+	168: "KEY_REWIND",
+	172: "KEY_HOMEPAGE",
+	200: "KEY_PLAYCD",
+	201: "KEY_PAUSECD",
+	207: "KEY_PLAY",
+	208: "KEY_FASTFORWARD",
+	226: "KEY_MEDIA",
+	352: "KEY_OK",
+	353: "KEY_SELECT",
+	357: "KEY_OPTION",
+	358: "KEY_INFO",
+	385: "KEY_RADIO",
+	402: "KEY_CHANNELUP",
+	403: "KEY_CHANNELDOWN",
+	407: "KEY_NEXT",
+	412: "KEY_PREVIOUS",
+}
+
+// KeyName キーの名前を返す。表に無いキーは "KEY_<番号>" とする。
+func KeyName(code uint16) string {
+	if s, ok := keyNames[code]; ok {
+		return s
+	}
+	return fmt.Sprintf("KEY_%d", code)
+}
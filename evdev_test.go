@@ -0,0 +1,60 @@
+package main
+
+import (
+	"local.packages/evdev"
+	"os"
+	"testing"
+)
+
+// 記録した通知を既定の割り当てで操作に直す。離した通知は送らない
+func TestEvdevKeys(t *testing.T) {
+	want := []RemoteAction{
+		{Action: "volup"},
+		{Action: "volup", Repeat: true},
+		{Action: "volup", Repeat: true},
+		{Action: "next"},
+		{Action: "toggle"},
+		{Action: "preset 1"},
+	}
+	for _, tt := range []struct {
+		path string
+		size int
+	}{
+		{"testdata/evdev/media16.bin", 16},
+		{"testdata/evdev/media24.bin", 24},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			f, err := os.Open(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			ev := make(chan evdev.Event)
+			code := make(chan ButtonCode, 16)
+			remote := make(chan RemoteAction, 16)
+			go func() {
+				evdev.ReadEvents(f, tt.size, ev)
+				close(ev)
+			}()
+			evdevKeys(ev, ConfigDefault().EvdevKeymap, code, remote)
+			close(remote)
+
+			var got []RemoteAction
+			for a := range remote {
+				got = append(got, a)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("actions %+v, want %+v", got, want)
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Errorf("action %d: %+v, want %+v", i, got[i], want[i])
+				}
+			}
+			if len(code) > 0 {
+				t.Errorf("%d buttons sent", len(code))
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// fixture ラジオが参照する外部サービスの代わりに testdata 以下の fixture を返す。
+// 開発用のサーバー(cmd/fixtureserver)とテストで使う。
+package fixture
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type station struct {
+	Name        string `json:"name"`
+	Tags        string `json:"tags"`
+	CountryCode string `json:"countrycode"`
+}
+
+// Handler dir 以下の fixture を返す。radio-browser.info の検索を模し、それ以外はファイルを返す
+func Handler(dir string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/json/stations/search", func(w http.ResponseWriter, r *http.Request) {
+		radioBrowserSearch(dir, w, r)
+	})
+	mux.Handle("/", http.FileServer(http.Dir(dir)))
+	return mux
+}
+
+// radioBrowserSearch radio-browser.info の /json/stations/search を模す
+func radioBrowserSearch(dir string, w http.ResponseWriter, r *http.Request) {
+	b, err := os.ReadFile(filepath.Join(dir, "radiobrowser", "stations.json"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var all []json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = len(all)
+	}
+	result := []json.RawMessage{}
+	for _, raw := range all {
+		var st station
+		if json.Unmarshal(raw, &st) != nil {
+			continue
+		}
+		if tag := q.Get("tag"); tag != "" &&
+			!strings.Contains(","+strings.ToLower(st.Tags)+",", ","+strings.ToLower(tag)+",") {
+			continue
+		}
+		if cc := q.Get("countrycode"); cc != "" && !strings.EqualFold(cc, st.CountryCode) {
+			continue
+		}
+		if name := q.Get("name"); name != "" &&
+			!strings.Contains(strings.ToLower(st.Name), strings.ToLower(name)) {
+			continue
+		}
+		result = append(result, raw)
+		if len(result) >= limit {
+			break
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
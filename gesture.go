@@ -0,0 +1,95 @@
+package main
+
+import (
+	"local.packages/rotaryencoder"
+	"time"
+)
+
+// Gesture ボタンとロータリーエンコーダの入力を組み合わせて、ダブルクリックや
+// ボタンを押したままの回転を判定してから Dispatch へ送る
+type Gesture struct {
+	held    bool // ボタンが押されている
+	rotated bool // 押している間に回された
+	pending bool // ダブルクリックを待っているクリック
+	timer   *time.Timer
+}
+
+func GestureNew() *Gesture {
+	g := &Gesture{timer: time.NewTimer(time.Hour)}
+	g.timer.Stop()
+	return g
+}
+
+// C ダブルクリックの待ち時間が過ぎた事を知らせるチャネル
+func (g *Gesture) C() <-chan time.Time {
+	return g.timer.C
+}
+
+// Button ボタンの入力を処理する。ループを継続する場合は false、中断する場合は true を返す
+func (g *Gesture) Button(btn ButtonCode) bool {
+	switch btn {
+	case btnStationReButtonDown:
+		g.held = true
+		g.rotated = false
+		return false
+	case btnStationReButtonUp:
+		// クリックや長押しは離す前に届くので、ここで1回の操作を終える
+		g.held = false
+		g.rotated = false
+		return false
+	}
+
+	if g.rotated {
+		// 押したまま回した後のクリックや長押しは捨てる
+		return false
+	}
+	if btn != BtnStationReButton {
+		if g.flush() {
+			return true
+		}
+		return radioState.Dispatch(btn)
+	}
+
+	if g.pending {
+		g.pending = false
+		g.timer.Stop()
+		return radioState.Dispatch(BtnStationReDoubleClick)
+	}
+	if config.DoubleClickWindow <= 0 || !radioState.AcceptsDoubleClick() {
+		return radioState.Dispatch(btn)
+	}
+	// 2回目のクリックを待つ
+	g.pending = true
+	g.timer.Reset(time.Duration(config.DoubleClickWindow) * time.Millisecond)
+	return false
+}
+
+// Rotate ロータリーエンコーダの入力を処理する
+func (g *Gesture) Rotate(s rotaryencoder.Step) bool {
+	if g.flush() {
+		return true
+	}
+	if g.held {
+		g.rotated = true
+		if s.Dir == rotaryencoder.Forward {
+			return radioState.Dispatch(BtnStationReHoldForward)
+		}
+		return radioState.Dispatch(BtnStationReHoldBackward)
+	}
+	return radioState.Rotate(ButtonCode(s.Dir), s.Interval)
+}
+
+// Timeout ダブルクリックにならなかったのでクリックとして処理する
+func (g *Gesture) Timeout() bool {
+	return g.flush()
+}
+
+// flush 待たせているクリックがあれば処理する
+func (g *Gesture) flush() bool {
+	if !g.pending {
+		return false
+	}
+	g.pending = false
+	g.timer.Stop()
+	return radioState.Dispatch(BtnStationReButton)
+}
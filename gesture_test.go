@@ -0,0 +1,44 @@
+package main
+
+import (
+	"local.packages/rotaryencoder"
+	"testing"
+)
+
+// 選局モードで本体のボタンを操作した後の状態を調べる。選局モードにはダブルクリックが無いので
+// クリックは待たずに処理する
+func TestGesture(t *testing.T) {
+	down, up := btnStationReButtonDown, btnStationReButtonUp
+	forward := rotaryencoder.Step{Dir: rotaryencoder.Forward}
+	for _, c := range []struct {
+		name   string
+		inputs []any // ButtonCode か rotaryencoder.Step
+		want   StateCode
+	}{
+		{"click", []any{down, BtnStationReButton, up}, stateVolumeSet},
+		{"long press", []any{down, BtnStationReButtonLong, up}, stateSelectFunction},
+		{"press and rotate", []any{down, forward, BtnStationReButton, up}, stateTuneMode},
+		{"remote click after press and rotate", []any{down, forward, BtnStationReButton, up, BtnStationReButton}, stateVolumeSet},
+		{"long press after press and rotate", []any{down, forward, up, down, BtnStationReButtonLong, up}, stateSelectFunction},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			r := testRadio(t, `{}`)
+			r.input(JournalEntry{Kind: journalAction, Action: "toggle"})
+			r.input(JournalEntry{Kind: journalAction, Action: "tunemode"})
+
+			g := GestureNew()
+			for _, in := range c.inputs {
+				switch in := in.(type) {
+				case ButtonCode:
+					g.Button(in)
+				case rotaryencoder.Step:
+					g.Rotate(in)
+				}
+			}
+			r.settle()
+			if s := radioState.GetState(); s != c.want {
+				t.Errorf("state %s, want %s", stateName(s), stateName(c.want))
+			}
+		})
+	}
+}
@@ -8,17 +8,25 @@ replace local.packages/volume => ./volume
 
 replace local.packages/rotaryencoder => ./rotaryencoder
 
+replace local.packages/gpioevent => ./gpioevent
+
+replace local.packages/irremote => ./irremote
+
+replace local.packages/evdev => ./evdev
+
 require (
+	github.com/carlmjohnson/requests v0.25.1
 	github.com/davecheney/i2c v0.0.0-20140823063045-caf08501bef2
 	github.com/sakaisatoru/go_mpvradio/netradio v0.0.0-20260712142908-a5600720cb47
 	github.com/sakaisatoru/go_radio_raspi/mpvctl v0.0.0-20260711065057-a1f510d3716d
 	github.com/stianeikeland/go-rpio/v4 v4.6.0
+	golang.org/x/text v0.40.0
 	local.packages/aqm0802a v0.0.0-00010101000000-000000000000
+	local.packages/evdev v0.0.0-00010101000000-000000000000
+	local.packages/gpioevent v0.0.0-00010101000000-000000000000
+	local.packages/irremote v0.0.0-00010101000000-000000000000
 	local.packages/rotaryencoder v0.0.0-00010101000000-000000000000
 	local.packages/volume v0.0.0-00010101000000-000000000000
 )
 
-require (
-	github.com/carlmjohnson/requests v0.25.1 // indirect
-	golang.org/x/net v0.38.0 // indirect
-)
+require golang.org/x/net v0.38.0 // indirect
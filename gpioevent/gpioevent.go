@@ -0,0 +1,161 @@
+// gpioevent Linux の GPIO キャラクタデバイス（/dev/gpiochipN, uAPI v2）で
+// 入力端子の変化を割り込みで受け取る
+package gpioevent
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	maxLines = 64
+	maxAttrs = 10
+
+	ioctlGetLine   = 0xC250B407 // GPIO_V2_GET_LINE_IOCTL
+	ioctlGetValues = 0xC010B40E // GPIO_V2_LINE_GET_VALUES_IOCTL
+
+	// GPIO_V2_LINE_FLAG_*（1 << 3 は出力）
+	flagInput       = 1 << 2
+	flagEdgeRising  = 1 << 4
+	flagEdgeFalling = 1 << 5
+	flagBiasPullUp  = 1 << 8
+
+	attrIDDebounce = 3
+
+	eventRisingEdge = 1
+	eventSize       = 48
+)
+
+// Event 端子の変化
+type Event struct {
+	Offset int           // 端子の番号（BCM）
+	Rising bool          // 立ち上がりなら true
+	Time   time.Duration // カーネルが記録した時刻（CLOCK_MONOTONIC）
+}
+
+type lineAttribute struct {
+	ID      uint32
+	Padding uint32
+	Value   uint64
+}
+
+type lineConfigAttribute struct {
+	Attr lineAttribute
+	Mask uint64
+}
+
+type lineConfig struct {
+	Flags    uint64
+	NumAttrs uint32
+	Padding  [5]uint32
+	Attrs    [maxAttrs]lineConfigAttribute
+}
+
+type lineRequest struct {
+	Offsets         [maxLines]uint32
+	Consumer        [32]byte
+	Config          lineConfig
+	NumLines        uint32
+	EventBufferSize uint32
+	Padding         [5]uint32
+	Fd              int32
+}
+
+type lineValues struct {
+	Bits uint64
+	Mask uint64
+}
+
+// Lines まとめて要求した入力端子
+type Lines struct {
+	f       *os.File
+	offsets []int
+}
+
+// Open 端子を入力（プルアップ、両エッジ検出）として要求する。
+// debounce が 0 でなければカーネルでチャタリングを取り除く。
+func Open(chip string, consumer string, offsets []int, debounce time.Duration) (*Lines, error) {
+	if len(offsets) == 0 || len(offsets) > maxLines {
+		return nil, errors.New("gpioevent: invalid number of lines")
+	}
+	c, err := os.Open(chip)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	req := newLineRequest(consumer, offsets, debounce)
+	if err := ioctl(c.Fd(), ioctlGetLine, unsafe.Pointer(&req)); err != nil {
+		return nil, err
+	}
+	return &Lines{
+		f:       os.NewFile(uintptr(req.Fd), chip),
+		offsets: append([]int(nil), offsets...),
+	}, nil
+}
+
+// newLineRequest 端子を入力（プルアップ、両エッジ検出）として要求する内容を作る
+func newLineRequest(consumer string, offsets []int, debounce time.Duration) lineRequest {
+	var req lineRequest
+	for i, o := range offsets {
+		req.Offsets[i] = uint32(o)
+	}
+	copy(req.Consumer[:len(req.Consumer)-1], consumer)
+	req.NumLines = uint32(len(offsets))
+	req.Config.Flags = flagInput | flagEdgeRising | flagEdgeFalling | flagBiasPullUp
+	if debounce > 0 {
+		req.Config.NumAttrs = 1
+		req.Config.Attrs[0].Attr.ID = attrIDDebounce
+		req.Config.Attrs[0].Attr.Value = uint64(debounce / time.Microsecond)
+		req.Config.Attrs[0].Mask = 1<<uint(len(offsets)) - 1
+	}
+	return req
+}
+
+// Values 端子の現在の値を Open で指定した順に返す
+func (l *Lines) Values() ([]int, error) {
+	v := lineValues{Mask: 1<<uint(len(l.offsets)) - 1}
+	if err := ioctl(l.f.Fd(), ioctlGetValues, unsafe.Pointer(&v)); err != nil {
+		return nil, err
+	}
+	rv := make([]int, len(l.offsets))
+	for i := range rv {
+		rv[i] = int(v.Bits>>uint(i)) & 1
+	}
+	return rv, nil
+}
+
+// Loop 端子の変化を ch へ送り続ける。Close されるとエラーを返して終わる。
+func (l *Lines) Loop(ch chan<- Event) error {
+	buf := make([]byte, eventSize*16)
+	for {
+		n, err := l.f.Read(buf)
+		if err != nil {
+			return err
+		}
+		for b := buf[:n]; len(b) >= eventSize; b = b[eventSize:] {
+			ch <- Event{
+				Offset: int(binary.NativeEndian.Uint32(b[12:])),
+				Rising: binary.NativeEndian.Uint32(b[8:]) == eventRisingEdge,
+				Time:   time.Duration(binary.NativeEndian.Uint64(b[0:])),
+			}
+		}
+	}
+}
+
+// Close 端子を解放する
+func (l *Lines) Close() error {
+	return l.f.Close()
+}
+
+func ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	if e != 0 {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package gpioevent
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestLineRequest(t *testing.T) {
+	req := newLineRequest("radio", []int{5, 6, 13}, 2*time.Millisecond)
+	// linux/gpio.h の GPIO_V2_LINE_FLAG_INPUT | EDGE_RISING | EDGE_FALLING | BIAS_PULL_UP
+	if want := uint64(0x04 | 0x10 | 0x20 | 0x100); req.Config.Flags != want {
+		t.Errorf("flags %#x, want %#x", req.Config.Flags, want)
+	}
+	if req.NumLines != 3 || req.Offsets[2] != 13 {
+		t.Errorf("lines %d %v", req.NumLines, req.Offsets[:3])
+	}
+	if a := req.Config.Attrs[0]; req.Config.NumAttrs != 1 || a.Attr.ID != attrIDDebounce || a.Attr.Value != 2000 || a.Mask != 7 {
+		t.Errorf("debounce %+v", a)
+	}
+	// struct gpio_v2_line_request の大きさ
+	if n := unsafe.Sizeof(req); n != 592 {
+		t.Errorf("sizeof lineRequest %d", n)
+	}
+}
+
+// lineEvent struct gpio_v2_line_event を作る
+func lineEvent(ts uint64, id, offset uint32) []byte {
+	b := make([]byte, eventSize)
+	binary.NativeEndian.PutUint64(b[0:], ts)
+	binary.NativeEndian.PutUint32(b[8:], id)
+	binary.NativeEndian.PutUint32(b[12:], offset)
+	return b
+}
+
+// 端子の代わりに pipe から読んだエッジのイベントを受け取る
+func TestLoop(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &Lines{f: r, offsets: []int{5, 6}}
+	ch := make(chan Event, 4)
+	done := make(chan error, 1)
+	go func() { done <- l.Loop(ch) }()
+
+	w.Write(append(lineEvent(1000, 1, 5), lineEvent(2000, 2, 6)...))
+	want := []Event{{Offset: 5, Rising: true, Time: 1000}, {Offset: 6, Rising: false, Time: 2000}}
+	for _, e := range want {
+		select {
+		case got := <-ch:
+			if got != e {
+				t.Errorf("event %+v, want %+v", got, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no event")
+		}
+	}
+	w.Close()
+	if err := <-done; err == nil {
+		t.Error("Loop did not end")
+	}
+	l.Close()
+}
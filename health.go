@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type StationHealth int
+
+const (
+	healthUnknown StationHealth = iota // 未確認あるいは確認できない局
+	healthAlive
+	healthDead
+)
+
+const (
+	deadStationMark     string = "x"
+	healthRedirectLimit int    = 3
+	healthDeadAfter     int    = 3 // 続けてこの回数応答が無ければ応答の無い局とする
+)
+
+// HealthStatus 局の死活確認の結果
+type HealthStatus struct {
+	Health  StationHealth
+	Latency time.Duration
+	Checked time.Time
+	Fails   int // 続けて応答の無かった回数
+	Err     error
+}
+
+// healthTarget 確認する局。局は並べ替えても変わらない order で区別する
+type healthTarget struct {
+	order int
+	urls  []string
+}
+
+// HealthChecker 局のURLを定期的に調べて死活状態を局ごとに記録する
+type HealthChecker struct {
+	mu       sync.Mutex
+	targets  []healthTarget
+	status   map[int]HealthStatus
+	interval time.Duration
+	timeout  time.Duration
+	wakeup   chan struct{}
+}
+
+var (
+	errHealthNotChecked = errors.New("not checked")
+)
+
+func HealthCheckerNew(interval, timeout time.Duration) *HealthChecker {
+	return &HealthChecker{
+		status:   make(map[int]HealthStatus),
+		interval: interval,
+		timeout:  timeout,
+		wakeup:   make(chan struct{}, 1),
+	}
+}
+
+// SetStations 確認対象の局を設定し、すぐに確認を始める。予備のURLも確認する
+func (h *HealthChecker) SetStations(st []StationInfo) {
+	h.mu.Lock()
+	h.targets = h.targets[:0]
+	for _, s := range st {
+		h.targets = append(h.targets, healthTarget{order: s.order, urls: s.Urls()})
+	}
+	h.mu.Unlock()
+
+	select {
+	case h.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Status 局（order）の確認結果を返す
+func (h *HealthChecker) Status(order int) HealthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st, ok := h.status[order]
+	if !ok {
+		return HealthStatus{Health: healthUnknown, Err: errHealthNotChecked}
+	}
+	return st
+}
+
+// IsDead 局（order）のどのURLも続けて応答しなかったかを返す
+func (h *HealthChecker) IsDead(order int) bool {
+	return h.Status(order).Health == healthDead
+}
+
+// Loop 一定間隔で全局を確認する。goroutine として起動する。
+func (h *HealthChecker) Loop() {
+	t := time.NewTimer(h.interval)
+	for {
+		select {
+		case <-h.wakeup:
+			if !t.Stop() {
+				select {
+				case <-t.C:
+				default:
+				}
+			}
+		case <-t.C:
+		}
+		h.checkAll()
+		t.Reset(h.interval)
+	}
+}
+
+func (h *HealthChecker) checkAll() {
+	h.mu.Lock()
+	targets := append([]healthTarget(nil), h.targets...)
+	h.mu.Unlock()
+
+	for _, t := range targets {
+		st := h.checkStation(t.urls, h.Status(t.order))
+		if st.Health == healthDead {
+			log.Printf("health: %s dead (%v)", t.urls[0], st.Err)
+		}
+		h.mu.Lock()
+		h.status[t.order] = st
+		h.mu.Unlock()
+	}
+}
+
+// checkStation 局の全てのURLを順に確認し、どれか1つが応答すれば応答のある局とする。
+// どれも応答しなければ前回までの失敗に数え、healthDeadAfter 回続いたら応答の無い局とする。
+func (h *HealthChecker) checkStation(urls []string, prev HealthStatus) HealthStatus {
+	checked := false
+	var last HealthStatus
+	for _, u := range urls {
+		st := h.check(u)
+		switch st.Health {
+		case healthAlive:
+			return st
+		case healthDead:
+			checked = true
+			last = st
+		}
+	}
+	if !checked {
+		// どのURLも確認できなかった
+		return HealthStatus{Health: healthUnknown, Checked: timeNow(), Err: errHealthNotChecked}
+	}
+	last.Fails = prev.Fails + 1
+	last.Health = prev.Health
+	if last.Fails >= healthDeadAfter {
+		last.Health = healthDead
+	}
+	return last
+}
+
+// check URLを1つ確認する
+func (h *HealthChecker) check(u string) HealthStatus {
+	st := HealthStatus{Checked: timeNow()}
+
+	// plugin: は問い合わせの軽いものだけURLを求めて確認する
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	u, ok, err := ResolveForProbe(ctx, u)
+	cancel()
+	if !ok {
+		st.Err = errHealthNotChecked
+		return st
+	}
+	if err != nil || u == "" {
+		st.Health = healthDead
+		st.Err = err
+		return st
+	}
+
+	// 応答時間は時計を偽物にしても実際の時間で測る
+	start := time.Now()
+	err = h.handshake(u, healthRedirectLimit)
+	st.Latency = time.Since(start)
+	st.Err = err
+	if err != nil {
+		st.Health = healthDead
+	} else {
+		st.Health = healthAlive
+	}
+	return st
+}
+
+// handshake 配信サーバーへ GET を送り、ステータス行とヘッダーを受け取るまでを確認する。
+// SHOUTcast の "ICY 200 OK" 応答も受け付ける。
+func (h *HealthChecker) handshake(rawurl string, redirect int) error {
+	pu, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+	port := "80"
+	switch pu.Scheme {
+	case "http":
+	case "https":
+		port = "443"
+	case "mms", "mmsh":
+		// mpv は mms:// を HTTP (mmsh) で接続する
+		pu.Scheme = "http"
+	default:
+		return fmt.Errorf("unsupported scheme %s", pu.Scheme)
+	}
+	host := pu.Host
+	if pu.Port() == "" {
+		host = net.JoinHostPort(pu.Hostname(), port)
+	}
+
+	d := &net.Dialer{Timeout: h.timeout}
+	var conn net.Conn
+	if pu.Scheme == "https" {
+		conn, err = tls.DialWithDialer(d, "tcp", host, &tls.Config{ServerName: pu.Hostname()})
+	} else {
+		conn, err = d.Dial("tcp", host)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(h.timeout))
+
+	req, err := http.NewRequest("GET", pu.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Icy-MetaData", "1")
+	req.Header.Set("User-Agent", httpUserAgent)
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+	line, err := r.Peek(4)
+	if err != nil {
+		return err
+	}
+	if string(line) == "ICY " {
+		status, _ := r.ReadString('\n')
+		if strings.HasPrefix(status, "ICY 200") {
+			return nil
+		}
+		return fmt.Errorf("%s", strings.TrimSpace(status))
+	}
+
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		loc, err := resp.Location()
+		if err != nil {
+			return err
+		}
+		if redirect <= 0 {
+			return errors.New("too many redirects")
+		}
+		return h.handshake(loc.String(), redirect-1)
+	case resp.StatusCode >= 400:
+		return httpStatusError(resp)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// deadURL 接続を拒むURLを返す
+func deadURL(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := "http://" + l.Addr().String() + "/stream"
+	l.Close()
+	return u
+}
+
+// testStation 局リストの order 番目の局。urls の2つ目以降は予備のURL
+func testStation(order int, urls ...string) StationInfo {
+	st := StationInfo{Alternates: urls[1:], order: order}
+	st.Url = urls[0]
+	return st
+}
+
+func TestHealthCheckAlternate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	h := HealthCheckerNew(time.Minute, time.Second)
+	h.SetStations([]StationInfo{
+		testStation(0, deadURL(t), srv.URL),
+		testStation(1, deadURL(t)),
+	})
+	for i := 1; i <= healthDeadAfter; i++ {
+		h.checkAll()
+		if h.IsDead(0) {
+			t.Fatalf("round %d: station with a working alternate is dead", i)
+		}
+		if dead := h.IsDead(1); dead != (i >= healthDeadAfter) {
+			t.Errorf("round %d: dead %v", i, dead)
+		}
+	}
+	if st := h.Status(0); st.Health != healthAlive || st.Fails != 0 {
+		t.Errorf("alternate status %+v", st)
+	}
+}
+
+func TestHealthCheckRecover(t *testing.T) {
+	h := HealthCheckerNew(time.Minute, time.Second)
+	h.SetStations([]StationInfo{testStation(3, deadURL(t))})
+	for i := 0; i < healthDeadAfter; i++ {
+		h.checkAll()
+	}
+	if !h.IsDead(3) {
+		t.Fatal("not dead")
+	}
+
+	// 応答が戻れば1回で戻す
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	h.SetStations([]StationInfo{testStation(3, srv.URL)})
+	h.checkAll()
+	if h.IsDead(3) {
+		t.Error("still dead")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+)
+
+const (
+	stationHistoryMax int = 8 // 覚えておく局の数
+)
+
+// pushHistory 再生を始めた局を履歴の先頭に置く。局は並べ替えても変わらない order で覚える。
+func (v *RadioState) pushHistory() {
+	if v.stationListLen == 0 {
+		return
+	}
+	o := v.stationList[v.lastpos].order
+	h := []int{o}
+	for _, p := range v.history {
+		if p != o && len(h) < stationHistoryMax {
+			h = append(h, p)
+		}
+	}
+	v.history = h
+}
+
+// historyIndex 履歴の i 番目（0 が今の局）の局リストでの位置を返す。局リストに無ければ false を返す
+func (v *RadioState) historyIndex(i int) (int, bool) {
+	for n, s := range v.stationList {
+		if s.order == v.history[i] {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// HasHistory 切り替えられる前の局があるかを返す
+func (v *RadioState) HasHistory() bool {
+	return len(v.history) > 1
+}
+
+// actSwap 1つ前に再生した局へすぐに切り替える。もう一度行えば元の局へ戻る
+func (v *RadioState) actSwap(arg string) {
+	if !v.HasHistory() {
+		return
+	}
+	n, ok := v.historyIndex(1)
+	if !ok {
+		return
+	}
+	v.pos = n
+	tune()
+}
+
+// showHistory 履歴で選んでいる局を表示する。選んでいる間は時間が経っても戻さず、
+// 確定しないまま出た時に leaveHistory で元の局に戻す。
+func (v *RadioState) showHistory() {
+	if n, ok := v.historyIndex(v.historyPos); ok {
+		v.pos = n
+	}
+	infomation.Update(0, v.CurrentStationLabel())
+	lcd.PrintWithPos(0, uint8(1), []byte(v.GetStateString(1)))
+}
+
+// stepHistory 履歴で選ぶ局を d だけ古い方へ動かす（端で止まる）
+func (v *RadioState) stepHistory(d int) {
+	CancelTune()
+	v.historyPos = min(max(v.historyPos+d, 0), len(v.history)-1)
+	v.showHistory()
+}
+
+// historyStateString 履歴の2行目（何番目に新しい局か）を返す
+func (v *RadioState) historyStateString() string {
+	return fmt.Sprintf("%8s", fmt.Sprintf("%d/%d", v.historyPos+1, len(v.history)))
+}
+
+// leaveHistory 履歴を出る時、選んだまま再生しなかった局を元に戻す
+func (v *RadioState) leaveHistory() {
+	v.restoreTimer.Stop()
+	if v.IsCannelChange() && !(v.IsTuning() && v.TuningIndex() == v.pos) {
+		v.restoreStation()
+	}
+}
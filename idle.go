@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// 状態ごとの入力が無ければ戻るまでの時間
+	idleDurations = map[StateCode]time.Duration{}
+)
+
+// IdleDurationsNew 設定ファイルの状態の名前を状態に直す。名前の誤りはまとめてエラーで返す。
+func IdleDurationsNew(c IdleTimeouts) (map[StateCode]time.Duration, error) {
+	var errs []error
+
+	m := make(map[StateCode]time.Duration)
+	for sn, sec := range c {
+		s, ok := stateNames[sn]
+		if !ok {
+			errs = append(errs, fmt.Errorf("idle_timeouts: %s: 不明な状態", sn))
+			continue
+		}
+		if sec > 0 {
+			m[s] = time.Duration(sec) * time.Second
+		}
+	}
+	return m, errors.Join(errs...)
+}
+
+// resetIdle 入力が無ければ戻る時刻を今の状態にあわせて設定し直す
+func (v *RadioState) resetIdle() {
+	d, ok := idleDurations[v.currState]
+	if !ok {
+		v.idleUntil = time.Time{}
+		return
+	}
+	v.idleUntil = timeNow().Add(d)
+}
+
+// IdleCheck 入力の無いまま時間が経っていれば入力待ち（ラジオが鳴っていれば音量調整）へ戻る。
+// 確定していない選局は取り消し、アラーム時刻は設定したものとする（状態を出る時に保存する）。
+// 設定メニューで編集中の値は捨てる。
+func (v *RadioState) IdleCheck() {
+	if v.idleUntil.IsZero() || timeNow().Before(v.idleUntil) {
+		return
+	}
+	v.idleUntil = time.Time{}
+	if v.restoreTimer.Stop() {
+		v.restoreStation()
+	}
+	if v.radioEnable && v.IsBrowsing() {
+		// 検索結果等の表示を局名に戻す
+		infomation.Update(0, v.CurrentStationName())
+	}
+	v.Event(eventIdle)
+}
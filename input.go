@@ -0,0 +1,139 @@
+package main
+
+import (
+	"local.packages/gpioevent"
+	"local.packages/rotaryencoder"
+	"log"
+	"time"
+)
+
+const (
+	inputModePoll string = "poll" // 端子を一定間隔で読む
+	inputModeEdge string = "edge" // GPIO キャラクタデバイスで端子の変化を待つ
+
+	btnTick          time.Duration = 10 * time.Millisecond // btninput の読み取り間隔に合わせる
+	btnEdgeDebounce  time.Duration = 5 * time.Millisecond
+	inputConsumerTag string        = "go_radio_br_zero"
+)
+
+// startInput ボタンとロータリーエンコーダの入力を始める。エッジ検出が使えなければ
+// 従来の読み取りに切り替える。
+func startInput(code chan<- ButtonCode, recode chan<- rotaryencoder.Step, re *rotaryencoder.RotaryEncoder) {
+	if config.InputMode != inputModePoll {
+		err := startEdgeInput(code, recode, re)
+		if err == nil {
+			return
+		}
+		log.Printf("%s: %v 端子の読み取りに切り替えます", config.GPIOChip, err)
+	}
+	go btninput(code)
+	go re.DetectStepLoop(recode)
+}
+
+// startEdgeInput GPIO キャラクタデバイスで入力を始める
+func startEdgeInput(code chan<- ButtonCode, recode chan<- rotaryencoder.Step, re *rotaryencoder.RotaryEncoder) error {
+	btn, err := gpioevent.Open(config.GPIOChip, inputConsumerTag, []int{pinReButton}, btnEdgeDebounce)
+	if err != nil {
+		return err
+	}
+	enc, err := gpioevent.Open(config.GPIOChip, inputConsumerTag, []int{pinReA, pinReB}, 0)
+	if err != nil {
+		btn.Close()
+		return err
+	}
+	v, err := enc.Values()
+	if err != nil {
+		btn.Close()
+		enc.Close()
+		return err
+	}
+
+	btnev := make(chan gpioevent.Event)
+	press := make(chan bool)
+	go func() {
+		if err := btn.Loop(btnev); err != nil {
+			log.Println(err)
+		}
+	}()
+	go func() {
+		for e := range btnev {
+			press <- !e.Rising // プルアップなので押すと Low
+		}
+	}()
+	go btnEdgeInput(press, code, lcd.OneShotLight)
+
+	encev := make(chan gpioevent.Event)
+	levels := make(chan uint8)
+	go func() {
+		if err := enc.Loop(encev); err != nil {
+			log.Println(err)
+		}
+	}()
+	go encoderLevels(encev, uint8(v[0]<<1|v[1]), levels)
+	go re.FeedLoop(levels, recode)
+	return nil
+}
+
+// encoderLevels エンコーダの A、B 端子の変化を A<<1|B の値に直して送る
+func encoderLevels(ev <-chan gpioevent.Event, current uint8, levels chan<- uint8) {
+	levels <- current // 解読の起点とする
+	for e := range ev {
+		bit := uint8(1) // B
+		if e.Offset == pinReA {
+			bit = 2
+		}
+		if e.Rising {
+			current |= bit
+		} else {
+			current &^= bit
+		}
+		levels <- current
+	}
+}
+
+// btnEdgeInput 押した・離したの通知からボタンのコードを作る。btninput と同じ判定を行う。
+// 押されている間だけ時間を計るので、押されていない時は何もしない。light はバックライトの点灯用。
+func btnEdgeInput(press <-chan bool, code chan<- ButtonCode, light func()) {
+	var (
+		held  bool
+		start time.Time
+		tick  *time.Ticker
+		tc    <-chan time.Time
+	)
+
+	for {
+		select {
+		case p, ok := <-press:
+			if !ok {
+				return
+			}
+			if p == held {
+				continue
+			}
+			held = p
+			if held {
+				start = time.Now()
+				tick = time.NewTicker(btnTick)
+				tc = tick.C
+				code <- btnStationReButtonDown
+				continue
+			}
+			tick.Stop()
+			tc = nil
+			hold := int(time.Since(start) / btnTick)
+			if hold >= btnPressLongWidth {
+				code <- BtnStationReButtonLong // リピート入力の終わり(ボタン長押し)
+			} else if hold > btnPressWidth {
+				light()
+				code <- BtnStationReButton // ワンショット入力
+			}
+			code <- btnStationReButtonUp
+
+		case <-tc:
+			if int(time.Since(start)/btnTick) > btnPressLongWidth {
+				light()
+				code <- BtnStationReButtonRepeat // リピート入力
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"local.packages/gpioevent"
+	"local.packages/irremote"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	irPresetPages int = 9 // 学習画面に並べる preset の数
+)
+
+var (
+	irmap = make(map[string]string) // リモコンのボタン（Code.Key()） -> 操作
+)
+
+// LoadIRMap 学習したリモコンの割り当てを読み込む。ファイルが無ければ空とする。
+func LoadIRMap(path string) (map[string]string, error) {
+	m := make(map[string]string)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return m, nil
+		}
+		return m, err
+	}
+	err = json.Unmarshal(b, &m)
+	return m, err
+}
+
+// SaveIRMap 学習したリモコンの割り当てを保存する
+func SaveIRMap(path string, m map[string]string) error {
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// startIRInput 赤外線受光モジュールの出力の変化を待って解読する。ir_pin が 0 なら何もしない。
+func startIRInput(code chan<- irremote.Code) {
+	if config.IRPin <= 0 {
+		return
+	}
+	l, err := gpioevent.Open(config.GPIOChip, inputConsumerTag, []int{config.IRPin}, 0)
+	if err != nil {
+		log.Printf("%s: %v リモコンは使えません", config.GPIOChip, err)
+		return
+	}
+	ev := make(chan gpioevent.Event)
+	go func() {
+		if err := l.Loop(ev); err != nil {
+			log.Println(err)
+		}
+	}()
+	go irDecodeLoop(ev, code)
+}
+
+// irDecodeLoop 端子の変化の時刻からパルスの長さを求めて解読する。受光モジュールの出力は
+// 赤外線が来ている間 Low となる。しばらく変化が無ければフレームの終わりとして解読を促す。
+func irDecodeLoop(ev <-chan gpioevent.Event, code chan<- irremote.Code) {
+	var (
+		last    gpioevent.Event
+		started bool
+		flushed time.Duration // フレームの終わりとして既に渡した space の長さ
+	)
+
+	dec := irremote.New()
+	gap := time.NewTimer(irremote.FrameGap)
+	gap.Stop()
+	for {
+		select {
+		case e, ok := <-ev:
+			if !ok {
+				return
+			}
+			if started {
+				d := e.Time - last.Time - flushed
+				if c, ok := dec.Feed(e.Rising, d); ok {
+					code <- c
+				}
+			}
+			last = e
+			started = true
+			flushed = 0
+			gap.Reset(irremote.FrameGap)
+		case <-gap.C:
+			if last.Rising {
+				// space が続いている
+				flushed = irremote.FrameGap
+				if c, ok := dec.Feed(false, flushed); ok {
+					code <- c
+				}
+			}
+		}
+	}
+}
+
+// irLearnPages 学習画面で選べる操作
+func irLearnPages() []string {
+	var pages []string
+	for _, n := range ActionNames() {
+		switch n {
+		case "none", "preset", "irlearn", "irclear":
+			continue
+		}
+		pages = append(pages, n)
+	}
+	for i := 1; i <= irPresetPages; i++ {
+		pages = append(pages, fmt.Sprintf("preset %d", i))
+	}
+	return pages
+}
+
+// irLearnedCode 操作に割り当てたリモコンのボタンを返す
+func irLearnedCode(action string) string {
+	for k, a := range irmap {
+		if a == action {
+			return k
+		}
+	}
+	return ""
+}
+
+// irStateString 学習画面の2行目（割り当て済みのボタン）を返す
+func (v *RadioState) irStateString() string {
+	if v.irLearned.Protocol != "" && irmap[v.irLearned.Key()] == v.irPages[v.irPos] {
+		return v.irLearned.Short()
+	}
+	if irLearnedCode(v.irPages[v.irPos]) != "" {
+		return "learned "
+	}
+	return "--------"
+}
+
+// nextIRPage 学習画面の操作を d だけ動かす（端で折り返す）
+func (v *RadioState) nextIRPage(d int) {
+	v.irPos = (v.irPos + d + len(v.irPages)) % len(v.irPages)
+	v.irLearned = irremote.Code{}
+	infomation.Update(0, v.irPages[v.irPos])
+}
+
+// RunIR リモコンのボタンに割り当てた操作を実行する。学習画面では選んでいる操作に割り当てる。
+// ループを継続する場合は false、中断する場合は true を返す
+func (v *RadioState) RunIR(c irremote.Code) bool {
+	if v.currState == stateIRLearn {
+		if c.Repeat {
+			return false
+		}
+		a := v.irPages[v.irPos]
+		if k := irLearnedCode(a); k != "" {
+			delete(irmap, k)
+		}
+		irmap[c.Key()] = a
+		v.irLearned = c
+		v.resetIdle()
+		if err := SaveIRMap(config.IRKeymapFile, irmap); err != nil {
+			log.Println(err)
+			infomation.ShowError(ErrorHup)
+		}
+		return false
+	}
+
+	a, ok := irmap[c.Key()]
+	if !ok {
+		return false
+	}
+	return v.RunRemote(a, c.Repeat)
+}
+
+// actIRClear 選んでいる操作のリモコンの割り当てを外す
+func (v *RadioState) actIRClear(arg string) {
+	if k := irLearnedCode(v.irPages[v.irPos]); k != "" {
+		delete(irmap, k)
+		if err := SaveIRMap(config.IRKeymapFile, irmap); err != nil {
+			log.Println(err)
+		}
+	}
+	v.irLearned = irremote.Code{}
+}
@@ -0,0 +1,103 @@
+// irremote 赤外線リモコンの信号（受光モジュールの出力のパルス列）を解読する。
+// 受光モジュールに依存しないので、記録したパルス列からでも解読できる。
+package irremote
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FrameGap time.Duration = 10 * time.Millisecond // これ以上変化が無ければフレームの終わりとみなす
+)
+
+// Code 解読したリモコンのボタン
+type Code struct {
+	Protocol string // "NEC" あるいは "RC5"
+	Address  uint16
+	Command  uint16
+	Repeat   bool // 押し続けている（NEC のリピートコード、RC5 のトグルビットが変わらない）
+}
+
+// Key 学習テーブルのキー。Repeat は含めない。
+func (c Code) Key() string {
+	return fmt.Sprintf("%s:%04X:%02X", c.Protocol, c.Address, c.Command)
+}
+
+// Short LCD 向けの8文字の表示
+func (c Code) Short() string {
+	return fmt.Sprintf("%c %02X %02X  ", c.Protocol[0], c.Address&0xff, c.Command&0xff)[:8]
+}
+
+func (c Code) String() string {
+	if c.Repeat {
+		return c.Key() + " repeat"
+	}
+	return c.Key()
+}
+
+// Decoder パルスを1つずつ受け取って解読する。mark は赤外線が来ている（受光モジュールの出力が Low の）間。
+type Decoder interface {
+	Feed(mark bool, d time.Duration) (Code, bool)
+}
+
+// MultiDecoder 複数の方式の Decoder へ同じパルスを渡す
+type MultiDecoder []Decoder
+
+// New NEC と RC5 を解読する Decoder を返す
+func New() MultiDecoder {
+	return MultiDecoder{&NEC{}, &RC5{}}
+}
+
+func (m MultiDecoder) Feed(mark bool, d time.Duration) (Code, bool) {
+	var (
+		rv Code
+		ok bool
+	)
+	for _, dec := range m {
+		if c, f := dec.Feed(mark, d); f && !ok {
+			rv, ok = c, true
+		}
+	}
+	return rv, ok
+}
+
+// Pulse 記録したパルス
+type Pulse struct {
+	Mark     bool
+	Duration time.Duration
+}
+
+// ReadPulses 記録したパルス列を読む。mark を正、space を負のマイクロ秒で空白区切りに書く。
+// # から行末まではコメント。
+//
+//	+9000 -4500 +560 -560 +560 -1690 ...
+func ReadPulses(r io.Reader) ([]Pulse, error) {
+	var rv []Pulse
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		s, _, _ := strings.Cut(scanner.Text(), "#")
+		for _, f := range strings.Fields(s) {
+			n, err := strconv.Atoi(f)
+			if err != nil {
+				return rv, err
+			}
+			p := Pulse{Mark: n > 0, Duration: time.Duration(n) * time.Microsecond}
+			if n < 0 {
+				p.Duration = -p.Duration
+			}
+			rv = append(rv, p)
+		}
+	}
+	return rv, scanner.Err()
+}
+
+// within d が want の ±25% に入るかどうかを返す
+func within(d, want time.Duration) bool {
+	return d >= want*3/4 && d <= want*5/4
+}
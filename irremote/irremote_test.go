@@ -0,0 +1,67 @@
+package irremote
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// 記録したパルス列（リポジトリの testdata/ir）を解読する
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		file string
+		want []Code
+	}{
+		{"nec.txt", []Code{
+			{Protocol: "NEC", Address: 0x0000, Command: 0x45},
+			{Protocol: "NEC", Address: 0x0000, Command: 0x45, Repeat: true},
+			{Protocol: "NEC", Address: 0x0000, Command: 0x45, Repeat: true},
+			{Protocol: "NEC", Address: 0x1234, Command: 0x16},
+		}},
+		{"rc5.txt", []Code{
+			{Protocol: "RC5", Address: 0, Command: 0x0c},
+			{Protocol: "RC5", Address: 0, Command: 0x0c, Repeat: true},
+			{Protocol: "RC5", Address: 0, Command: 0x0c},
+			{Protocol: "RC5", Address: 0x14, Command: 0x41},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open("../testdata/ir/" + tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			pulses, err := ReadPulses(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []Code
+			dec := New()
+			for _, p := range pulses {
+				if c, ok := dec.Feed(p.Mark, p.Duration); ok {
+					got = append(got, c)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("decoded %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("code %d: %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReadPulses(t *testing.T) {
+	p, err := ReadPulses(strings.NewReader("# comment\n+9000 -4500 # frame\n+560\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p) != 3 || !p[0].Mark || p[1].Mark || p[1].Duration.Microseconds() != 4500 {
+		t.Errorf("pulses %+v", p)
+	}
+}
@@ -0,0 +1,112 @@
+package irremote
+
+import (
+	"time"
+)
+
+const (
+	necUnit        time.Duration = 562500 * time.Nanosecond
+	necLeaderMark  time.Duration = 16 * necUnit // 9ms
+	necLeaderSpace time.Duration = 8 * necUnit  // 4.5ms
+	necRepeatSpace time.Duration = 4 * necUnit  // 2.25ms
+	necOneSpace    time.Duration = 3 * necUnit
+	necRepeatLimit time.Duration = 200 * time.Millisecond // リピートコードを前のフレームの続きとみなす間隔
+)
+
+type necState int
+
+const (
+	necIdle necState = iota
+	necLeader
+	necBitMark
+	necBitSpace
+	necRepeatMark
+)
+
+// NEC NEC フォーマットの解読。アドレスは反転を含む16ビット（拡張 NEC）として扱い、
+// 8ビットのアドレスと反転が揃っていれば下位8ビットにする。
+type NEC struct {
+	state  necState
+	bits   uint32
+	nbits  int
+	last   Code
+	valid  bool // last が有効
+	lastAt time.Duration
+	clock  time.Duration // 受け取ったパルスの合計（リピートの間隔の判定用）
+}
+
+func (n *NEC) Feed(mark bool, d time.Duration) (Code, bool) {
+	n.clock += d
+	switch n.state {
+	case necIdle:
+		if mark && within(d, necLeaderMark) {
+			n.state = necLeader
+		}
+	case necLeader:
+		switch {
+		case !mark && within(d, necLeaderSpace):
+			n.state = necBitMark
+			n.bits = 0
+			n.nbits = 0
+		case !mark && within(d, necRepeatSpace):
+			n.state = necRepeatMark
+		default:
+			n.reset(mark, d)
+		}
+	case necBitMark:
+		if !mark || !within(d, necUnit) {
+			n.reset(mark, d)
+			break
+		}
+		if n.nbits == 32 {
+			// ストップビット
+			n.state = necIdle
+			return n.frame()
+		}
+		n.state = necBitSpace
+	case necBitSpace:
+		switch {
+		case !mark && within(d, necUnit):
+		case !mark && within(d, necOneSpace):
+			n.bits |= 1 << uint(n.nbits)
+		default:
+			n.reset(mark, d)
+			return Code{}, false
+		}
+		n.nbits++
+		n.state = necBitMark
+	case necRepeatMark:
+		n.state = necIdle
+		if mark && within(d, necUnit) && n.valid && n.clock-n.lastAt < necRepeatLimit {
+			n.lastAt = n.clock
+			c := n.last
+			c.Repeat = true
+			return c, true
+		}
+	}
+	return Code{}, false
+}
+
+// reset 解読をやめる。そのパルスがリーダーであればやり直す。
+func (n *NEC) reset(mark bool, d time.Duration) {
+	n.state = necIdle
+	if mark && within(d, necLeaderMark) {
+		n.state = necLeader
+	}
+}
+
+func (n *NEC) frame() (Code, bool) {
+	addr := uint16(n.bits)
+	cmd := uint8(n.bits >> 16)
+	if cmd != ^uint8(n.bits>>24) {
+		// コマンドと反転が合わない
+		return Code{}, false
+	}
+	if uint8(addr) == ^uint8(addr>>8) {
+		addr &= 0xff
+	}
+	n.last = Code{Protocol: "NEC", Address: addr, Command: uint16(cmd)}
+	n.valid = true
+	n.lastAt = n.clock
+	return n.last, true
+}
@@ -0,0 +1,100 @@
+package irremote
+
+import (
+	"time"
+)
+
+const (
+	rc5Half        time.Duration = 889 * time.Microsecond
+	rc5Bits        int           = 14
+	rc5RepeatLimit time.Duration = 250 * time.Millisecond // 押し続けている時のフレームの間隔の上限
+)
+
+// RC5 Philips RC5 フォーマット（マンチェスタ符号）の解読。2ビット目は拡張コマンドの
+// 7ビット目（反転）として扱う。トグルビットが前のフレームと同じなら押し続けているとみなす。
+type RC5 struct {
+	halves     []bool // 半ビット単位の mark/space
+	lastToggle int
+	last       Code
+	valid      bool // last が有効
+	lastAt     time.Duration
+	clock      time.Duration // 受け取ったパルスの合計（押し続けているかの判定用）
+}
+
+func (r *RC5) Feed(mark bool, d time.Duration) (Code, bool) {
+	r.clock += d
+
+	var n int
+	switch {
+	case within(d, rc5Half):
+		n = 1
+	case within(d, 2*rc5Half):
+		n = 2
+	default:
+		if mark {
+			r.halves = r.halves[:0]
+			return Code{}, false
+		}
+		// フレームの終わり。最後のビットが 0 なら後半の space はここに含まれる。
+		c, ok := r.frame(true, r.clock-d)
+		r.halves = r.halves[:0]
+		return c, ok
+	}
+
+	if len(r.halves) == 0 {
+		if !mark {
+			return Code{}, false
+		}
+		// 先頭のスタートビット(1)の前半の space は見えないので補う
+		r.halves = append(r.halves, false)
+	}
+	for i := 0; i < n; i++ {
+		r.halves = append(r.halves, mark)
+	}
+	if len(r.halves) >= 2*rc5Bits {
+		c, ok := r.frame(false, r.clock)
+		r.halves = r.halves[:0]
+		return c, ok
+	}
+	return Code{}, false
+}
+
+// frame 集めた半ビットを解読する。end はフレームの終わった時刻。
+func (r *RC5) frame(pad bool, end time.Duration) (Code, bool) {
+	h := r.halves
+	if pad && len(h) == 2*rc5Bits-1 {
+		h = append(h, false)
+	}
+	if len(h) < 2*rc5Bits {
+		return Code{}, false
+	}
+
+	var v uint16
+	for i := 0; i < rc5Bits; i++ {
+		a, b := h[2*i], h[2*i+1]
+		if a == b {
+			return Code{}, false
+		}
+		v <<= 1
+		if b {
+			// space -> mark が 1
+			v |= 1
+		}
+	}
+	if v&(1<<13) == 0 {
+		return Code{}, false
+	}
+	toggle := int(v>>11) & 1
+	cmd := v & 0x3f
+	if v&(1<<12) == 0 {
+		cmd |= 0x40
+	}
+	c := Code{Protocol: "RC5", Address: (v >> 6) & 0x1f, Command: cmd}
+	c.Repeat = r.valid && toggle == r.lastToggle && c.Address == r.last.Address &&
+		c.Command == r.last.Command && end-r.lastAt < rc5RepeatLimit
+	r.lastToggle = toggle
+	r.last = c
+	r.valid = true
+	r.lastAt = end
+	return c, true
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	journalButton = "button" // Dispatch へ送った入力
+	journalRotate = "rotate" // ロータリーエンコーダの1刻み
+	journalAction = "action" // リモコンのボタンに割り当てた操作
+	journalState  = "state"  // 状態の遷移
+)
+
+// JournalEntry 入力の記録の1行
+type JournalEntry struct {
+	Time     time.Time     `json:"time"`
+	Kind     string        `json:"kind"`
+	Code     string        `json:"code,omitempty"`     // button, rotate: 入力の名前
+	Interval time.Duration `json:"interval,omitempty"` // rotate: 前の刻みからの間隔（ナノ秒）
+	Action   string        `json:"action,omitempty"`   // action: 操作の名前
+	Repeat   bool          `json:"repeat,omitempty"`   // action: 押し続けた時の通知
+	From     string        `json:"from,omitempty"`     // state: 遷移前の状態
+	To       string        `json:"to,omitempty"`       // state: 遷移後の状態
+}
+
+// Journal 入力と状態の遷移を1行1件の JSON でファイルへ記録する。
+// ファイルが大きくなったら .1 .2 ... と名前を変えて backups 個まで残す。
+type Journal struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	f       *os.File
+	size    int64
+}
+
+var (
+	journal *Journal // nil なら記録しない
+)
+
+// JournalOpen 記録を始める。既にファイルがあれば続きに書く。
+func JournalOpen(path string, maxSize int64, backups int) (*Journal, error) {
+	j := &Journal{path: path, maxSize: maxSize, backups: backups}
+	if err := j.open(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *Journal) open() error {
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	j.f = f
+	j.size = st.Size()
+	return nil
+}
+
+// rotate 今のファイルを .1 に、.1 を .2 にと名前を変えて新しいファイルに書く
+func (j *Journal) rotate() error {
+	j.f.Close()
+	for i := j.backups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", j.path, i), fmt.Sprintf("%s.%d", j.path, i+1))
+	}
+	if j.backups > 0 {
+		os.Rename(j.path, j.path+".1")
+	} else {
+		os.Remove(j.path)
+	}
+	return j.open()
+}
+
+// Write 1件記録する。時刻が空なら今の時刻とする。
+func (j *Journal) Write(e JournalEntry) {
+	if j == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = timeNow()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	b = append(b, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return
+	}
+	if j.maxSize > 0 && j.size+int64(len(b)) > j.maxSize {
+		if err := j.rotate(); err != nil {
+			log.Println(err)
+			j.f = nil
+			return
+		}
+	}
+	n, err := j.f.Write(b)
+	j.size += int64(n)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// Close 記録を終える
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return nil
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
+
+// ReadJournal 記録を読む。読めない行は飛ばしてログに残す。
+func ReadJournal(r io.Reader) ([]JournalEntry, error) {
+	var rv []JournalEntry
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			log.Printf("%d: %v", n, err)
+			continue
+		}
+		rv = append(rv, e)
+	}
+	return rv, scanner.Err()
+}
+
+// stateName 状態の名前（keymap と同じもの）を返す
+func stateName(s StateCode) string {
+	for k, v := range stateNames {
+		if v == s {
+			return k
+		}
+	}
+	return fmt.Sprintf("state%d", s)
+}
+
+// buttonName 入力の名前（keymap と同じもの）を返す
+func buttonName(b ButtonCode) string {
+	for k, v := range buttonNames {
+		if v == b {
+			return k
+		}
+	}
+	return fmt.Sprintf("button%d", b)
+}
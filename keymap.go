@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// KeymapConfig 設定ファイルの割り当て。状態の名前 -> 入力の名前 -> 操作
+type KeymapConfig map[string]map[string]string
+
+// Keymap 状態ごとにボタンの入力へ操作の名前を割り当てる
+type Keymap map[StateCode]map[ButtonCode]string
+
+var (
+	keymap = KeymapDefault()
+
+	// 設定ファイルで使う状態の名前
+	stateNames = map[string]StateCode{
+		"normal":      stateNormalMode,
+		"volume":      stateVolumeSet,
+		"tune":        stateTuneMode,
+		"function":    stateSelectFunction,
+		"alarm_hour":  stateAlarmHourSet,
+		"alarm_min":   stateAlarmMinSet,
+		"dir_query":   stateDirQuery,
+		"dir_browse":  stateDirBrowse,
+		"episode":     stateEpisodeSelect,
+		"playback":    statePlayback,
+		"diagnostics": stateDiagnostics,
+		"ir_learn":    stateIRLearn,
+		"settings":    stateSettings,
+		"history":     stateHistory,
+	}
+	// 設定ファイルで使うボタンの入力の名前
+	buttonNames = map[string]ButtonCode{
+		"forward":       BtnStationReForward,
+		"backward":      BtnStationReBackward,
+		"click":         BtnStationReButton,
+		"long":          BtnStationReButtonLong,
+		"repeat":        BtnStationReButtonRepeat,
+		"double":        BtnStationReDoubleClick,
+		"hold_forward":  BtnStationReHoldForward,
+		"hold_backward": BtnStationReHoldBackward,
+	}
+	// 音量調整の中の曲送りで、音量の割り当てに代えて使う操作
+	trackSkipKeys = map[ButtonCode]string{
+		BtnStationReForward:  "nexttrack",
+		BtnStationReBackward: "prevtrack",
+	}
+)
+
+// KeymapDefault 既定の割り当てを返す
+func KeymapDefault() Keymap {
+	return Keymap{
+		stateNormalMode: {
+			BtnStationReForward:     "tune",
+			BtnStationReButton:      "tune",
+			BtnStationReBackward:    "search",
+			BtnStationReDoubleClick: "settings",
+			BtnStationReButtonLong:  "shutdown",
+		},
+		stateVolumeSet: {
+			BtnStationReForward:      "volup",
+			BtnStationReBackward:     "voldown",
+			BtnStationReButton:       "station",
+			BtnStationReDoubleClick:  "swap",
+			BtnStationReHoldBackward: "history",
+			BtnStationReButtonLong:   "off",
+		},
+		stateTuneMode: {
+			BtnStationReForward:      "next",
+			BtnStationReBackward:     "prev",
+			BtnStationReHoldForward:  "nextgroup",
+			BtnStationReHoldBackward: "prevgroup",
+			BtnStationReButton:       "tune",
+			BtnStationReButtonLong:   "function",
+		},
+		stateSelectFunction: {
+			BtnStationReButton:     "alarmcycle",
+			BtnStationReForward:    "diagnostics",
+			BtnStationReBackward:   "irlearn",
+			BtnStationReButtonLong: "home",
+		},
+		stateAlarmHourSet: {
+			BtnStationReForward:    "next",
+			BtnStationReBackward:   "prev",
+			BtnStationReButton:     "alarmmin",
+			BtnStationReButtonLong: "home",
+		},
+		stateAlarmMinSet: {
+			BtnStationReForward:    "next",
+			BtnStationReBackward:   "prev",
+			BtnStationReButton:     "function",
+			BtnStationReButtonLong: "home",
+		},
+		stateDirQuery: {
+			BtnStationReForward:    "next",
+			BtnStationReBackward:   "prev",
+			BtnStationReButton:     "find",
+			BtnStationReButtonLong: "home",
+		},
+		stateDirBrowse: {
+			BtnStationReForward:    "next",
+			BtnStationReBackward:   "prev",
+			BtnStationReButton:     "add",
+			BtnStationReButtonLong: "search",
+		},
+		stateEpisodeSelect: {
+			BtnStationReForward:    "next",
+			BtnStationReBackward:   "prev",
+			BtnStationReButton:     "play",
+			BtnStationReButtonLong: "tunemode",
+		},
+		statePlayback: {
+			BtnStationReForward:    "next",
+			BtnStationReBackward:   "prev",
+			BtnStationReButton:     "tunemode",
+			BtnStationReButtonLong: "off",
+		},
+		stateDiagnostics: {
+			BtnStationReForward:    "next",
+			BtnStationReBackward:   "prev",
+			BtnStationReButton:     "home",
+			BtnStationReButtonLong: "home",
+		},
+		stateIRLearn: {
+			BtnStationReForward:    "next",
+			BtnStationReBackward:   "prev",
+			BtnStationReButton:     "irclear",
+			BtnStationReButtonLong: "home",
+		},
+		stateSettings: {
+			BtnStationReForward:    "next",
+			BtnStationReBackward:   "prev",
+			BtnStationReButton:     "select",
+			BtnStationReButtonLong: "back",
+		},
+		stateHistory: {
+			BtnStationReForward:      "next",
+			BtnStationReBackward:     "prev",
+			BtnStationReHoldForward:  "next",
+			BtnStationReHoldBackward: "prev",
+			BtnStationReButton:       "tune",
+			BtnStationReButtonLong:   "home",
+		},
+	}
+}
+
+// KeymapNew 既定の割り当てに設定ファイルの割り当てを重ねる。"none" で割り当てを外せる。
+// 名前の誤りはまとめてエラーで返し、誤りの無い部分は反映する。
+//
+//	"keymap": {"volume": {"double": "mute", "hold_forward": "preset 1"}}
+func KeymapNew(conf KeymapConfig) (Keymap, error) {
+	var errs []error
+
+	k := KeymapDefault()
+	for sn, m := range conf {
+		s, ok := stateNames[sn]
+		if !ok {
+			errs = append(errs, fmt.Errorf("keymap: %s: 不明な状態", sn))
+			continue
+		}
+		for bn, a := range m {
+			b, ok := buttonNames[bn]
+			if !ok {
+				errs = append(errs, fmt.Errorf("keymap: %s.%s: 不明な入力", sn, bn))
+				continue
+			}
+			name, _ := parseAction(a)
+			if _, ok := actions[name]; !ok {
+				errs = append(errs, fmt.Errorf("keymap: %s.%s: %s 不明な操作", sn, bn, name))
+				continue
+			}
+			k[s][b] = a
+		}
+	}
+	return k, errors.Join(errs...)
+}
+
+// Lookup 状態と入力に割り当てられた操作の名前を返す。割り当てが無ければ "" を返す。
+func (k Keymap) Lookup(s StateCode, b ButtonCode) string {
+	a := k[s][b]
+	if a == "none" {
+		return ""
+	}
+	return a
+}
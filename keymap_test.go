@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// 設定ファイルの割り当ては既定の割り当てに重ね、"none" で外す
+func TestKeymapNew(t *testing.T) {
+	registerActions()
+	k, err := KeymapNew(KeymapConfig{
+		"volume": {"double": "mute", "hold_forward": "preset 1", "hold_backward": "none"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		s    StateCode
+		b    ButtonCode
+		want string
+	}{
+		{stateVolumeSet, BtnStationReDoubleClick, "mute"},
+		{stateVolumeSet, BtnStationReHoldForward, "preset 1"},
+		{stateVolumeSet, BtnStationReHoldBackward, ""},
+		// 書かなかった入力と状態は既定のまま
+		{stateVolumeSet, BtnStationReButton, "station"},
+		{stateTuneMode, BtnStationReHoldBackward, "prevgroup"},
+	} {
+		if got := k.Lookup(c.s, c.b); got != c.want {
+			t.Errorf("%s %d: %q, want %q", stateName(c.s), c.b, got, c.want)
+		}
+	}
+	// 既定の割り当ては書き換えない
+	if got := KeymapDefault().Lookup(stateVolumeSet, BtnStationReDoubleClick); got != "swap" {
+		t.Errorf("default changed: %q", got)
+	}
+}
+
+// 名前の誤りはまとめて返し、誤りの無い部分は反映する
+func TestKeymapNewErrors(t *testing.T) {
+	registerActions()
+	k, err := KeymapNew(KeymapConfig{
+		"volume":  {"double": "mute", "triple": "mute", "click": "jump"},
+		"nowhere": {"click": "tune"},
+	})
+	if err == nil {
+		t.Fatal("no error")
+	}
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) || len(joined.Unwrap()) != 3 {
+		t.Fatalf("errors not aggregated: %v", err)
+	}
+	for _, want := range []string{
+		"keymap: volume.triple: 不明な入力",
+		"keymap: volume.click: jump 不明な操作",
+		"keymap: nowhere: 不明な状態",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%q not in %q", want, err)
+		}
+	}
+	if got := k.Lookup(stateVolumeSet, BtnStationReDoubleClick); got != "mute" {
+		t.Errorf("valid entry not applied: %q", got)
+	}
+	if got := k.Lookup(stateVolumeSet, BtnStationReButton); got != "station" {
+		t.Errorf("invalid entry applied: %q", got)
+	}
+}
@@ -7,45 +7,52 @@ import (
 type Led struct {
 }
 
+var (
+	// 出力端子へ書く。記録の再生では端子の代わりに画面の記録へ書く
+	pinWrite = func(pin int, s rpio.State) {
+		rpio.WritePin(rpio.Pin(pin), s)
+	}
+)
+
 func LedNew() *Led {
 	return &Led{}
 }
 
 func (v *Led) GreenOn() {
-	rpio.Pin(pinReLed2).High() // 赤 OFF
-	rpio.Pin(pinReLed1).Low()  // 緑 ON
+	pinWrite(pinReLed2, rpio.High) // 赤 OFF
+	pinWrite(pinReLed1, rpio.Low)  // 緑 ON
 }
 
 func (v *Led) GreenOff() {
-	rpio.Pin(pinReLed1).High() // 緑 OFF
+	pinWrite(pinReLed1, rpio.High) // 緑 OFF
 }
 
 func (v *Led) RedOn() {
-	rpio.Pin(pinReLed1).High() // 緑 OFF
-	rpio.Pin(pinReLed2).Low()  // 赤 ON
+	pinWrite(pinReLed1, rpio.High) // 緑 OFF
+	pinWrite(pinReLed2, rpio.Low)  // 赤 ON
 }
 
 func (v *Led) RedOff() {
-	rpio.Pin(pinReLed2).High() // 赤 OFF
+	pinWrite(pinReLed2, rpio.High) // 赤 OFF
 }
 
 func (v *Led) YellowOn() {
-	rpio.Pin(pinReLed1).Low() // 緑 ON
-	rpio.Pin(pinReLed2).Low() // 赤 ON
+	pinWrite(pinReLed1, rpio.Low) // 緑 ON
+	pinWrite(pinReLed2, rpio.Low) // 赤 ON
 }
 
 func (v *Led) YellowOff() {
-	rpio.Pin(pinReLed1).High() // 緑 OFF
-	rpio.Pin(pinReLed2).High() // 赤 OFF
+	pinWrite(pinReLed1, rpio.High) // 緑 OFF
+	pinWrite(pinReLed2, rpio.High) // 赤 OFF
 }
 
 func (v *Led) ChangeColor(s StateCode) {
 	switch s {
-	case stateNormalMode, stateVolumeSet:
+	case stateNormalMode, stateVolumeSet, statePlayback:
 		v.GreenOn()
-	case stateTuneMode:
+	case stateTuneMode, stateDirQuery, stateDirBrowse, stateEpisodeSelect, stateHistory:
 		v.RedOn()
-	case stateSelectFunction, stateAlarmHourSet, stateAlarmMinSet:
+	case stateSelectFunction, stateAlarmHourSet, stateAlarmMinSet, stateDiagnostics, stateIRLearn, stateSettings:
 		v.YellowOn()
 	}
 }
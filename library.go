@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	libraryShuffle string = "?shuffle"
+)
+
+var (
+	audioExtensions = map[string]bool{
+		".mp3": true, ".m4a": true, ".aac": true, ".flac": true,
+		".ogg": true, ".oga": true, ".opus": true, ".wav": true, ".wma": true,
+	}
+)
+
+// fileResolver file:<パス> 手元の音声ファイルを1曲再生する
+type fileResolver struct {
+}
+
+func (r *fileResolver) Name() string {
+	return "file:"
+}
+
+func (r *fileResolver) Resolve(ctx context.Context, arg string) (Resolved, error) {
+	if _, err := os.Stat(arg); err != nil {
+		return Resolved{}, err
+	}
+	return Resolved{URL: arg, OnDemand: true, Playlist: true}, nil
+}
+
+// dirResolver dir:<ディレクトリ>[?shuffle] ディレクトリ以下の音声ファイルを順に（あるいは順不同で）再生する
+type dirResolver struct {
+	playlist string // mpv に読み込ませるプレイリストを書くファイル
+}
+
+func dirResolverNew(playlist string) *dirResolver {
+	return &dirResolver{playlist: playlist}
+}
+
+func (r *dirResolver) Name() string {
+	return "dir:"
+}
+
+func (r *dirResolver) Resolve(ctx context.Context, arg string) (Resolved, error) {
+	dir, shuffle := strings.CutSuffix(arg, libraryShuffle)
+	tracks, err := libraryTracks(dir)
+	if err != nil {
+		return Resolved{}, err
+	}
+	if len(tracks) == 0 {
+		return Resolved{}, fmt.Errorf("%s: 音声ファイルがありません", dir)
+	}
+	if shuffle {
+		rand.Shuffle(len(tracks), func(i, j int) {
+			tracks[i], tracks[j] = tracks[j], tracks[i]
+		})
+	}
+
+	// mpv にはプレイリストとして読み込ませる
+	b := []byte("#EXTM3U\n" + strings.Join(tracks, "\n") + "\n")
+	if err := os.WriteFile(r.playlist, b, 0644); err != nil {
+		return Resolved{}, err
+	}
+	return Resolved{URL: r.playlist, OnDemand: true, Playlist: true}, nil
+}
+
+// libraryTracks ディレクトリ以下の音声ファイルをパス順に返す
+func libraryTracks(dir string) ([]string, error) {
+	var tracks []string
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if audioExtensions[strings.ToLower(filepath.Ext(path))] {
+			tracks = append(tracks, path)
+		}
+		return nil
+	})
+	return tracks, err
+}
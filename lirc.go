@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lircRetryWait    time.Duration = 1 * time.Second  // lircd へ接続し直すまでの待ち時間
+	lircRetryWaitMax time.Duration = 30 * time.Second // 待ち時間の上限 接続できない間は倍にしていく
+)
+
+// LircKey lircd が知らせるボタン
+type LircKey struct {
+	Remote string // lircd.conf のリモコンの名前
+	Button string // ボタンの名前
+	Repeat int    // 押し続けている間 1 から増える
+}
+
+// parseLircLine lircd の通知を解く。書式は "<コード> <繰り返し(16進)> <ボタン> <リモコン>"
+//
+//	0000000000f40bf0 00 KEY_VOLUMEUP myremote
+func parseLircLine(s string) (LircKey, bool) {
+	f := strings.Fields(s)
+	if len(f) != 4 {
+		return LircKey{}, false
+	}
+	n, err := strconv.ParseUint(f[1], 16, 32)
+	if err != nil {
+		return LircKey{}, false
+	}
+	return LircKey{Remote: f[3], Button: f[2], Repeat: int(n)}, true
+}
+
+// lircLookup ボタンに割り当てた名前を返す。"リモコン:ボタン" の割り当てを "ボタン" より優先する。
+func lircLookup(m RemoteKeymap, k LircKey) (string, bool) {
+	if a, ok := m[k.Remote+":"+k.Button]; ok {
+		return a, true
+	}
+	a, ok := m[k.Button]
+	return a, ok
+}
+
+// startLircInput lircd のボタンを、入力の名前(forward、click 等)を割り当てたものは
+// ボタンのコードとして code へ、操作の名前を割り当てたものは remote へ送る。
+// lirc_socket が空なら何もしない。
+func startLircInput(code chan<- ButtonCode, remote chan<- RemoteAction) {
+	if config.LircSocket == "" {
+		return
+	}
+	keys := make(chan LircKey)
+	go lircLoop(config.LircSocket, keys)
+	go func() {
+		for k := range keys {
+			if a, ok := lircLookup(config.LircKeymap, k); ok {
+				sendRemote(a, k.Repeat > 0, code, remote)
+			}
+		}
+	}()
+}
+
+// lircLoop lircd に接続してボタンの通知を keys へ送る。切断されたら接続し直す。
+func lircLoop(path string, keys chan<- LircKey) {
+	wait := lircRetryWait
+	for {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			if wait == lircRetryWait {
+				log.Printf("lircd: %v 接続できるまで待ちます", err)
+			}
+			time.Sleep(wait)
+			wait = min(wait*2, lircRetryWaitMax)
+			continue
+		}
+		wait = lircRetryWait
+		log.Printf("lircd: %s に接続しました", path)
+		lircRead(conn, keys)
+		conn.Close()
+		log.Println("lircd: 切断されました")
+		time.Sleep(wait)
+	}
+}
+
+// lircRead 接続が切れるまで通知を読む。コマンドへの応答（BEGIN から END まで）は読み飛ばす。
+func lircRead(conn net.Conn, keys chan<- LircKey) {
+	reply := false
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		s := scanner.Text()
+		switch {
+		case s == "BEGIN":
+			reply = true
+		case s == "END":
+			reply = false
+		case reply:
+		default:
+			if k, ok := parseLircLine(s); ok {
+				keys <- k
+			}
+		}
+	}
+}
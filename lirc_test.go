@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeLircd lircd の代わりに接続を受け付ける。接続ごとに lines を送って切断する
+func fakeLircd(t *testing.T, path string, conns ...[]string) {
+	t.Helper()
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for _, lines := range conns {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			for _, s := range lines {
+				c.Write([]byte(s + "\n"))
+			}
+			c.Close()
+		}
+	}()
+}
+
+// lircd のボタンを既定の割り当てで入力と操作に直す。切断されたら接続し直す
+func TestLircInput(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config = ConfigDefault()
+	config.LircSocket = filepath.Join(t.TempDir(), "lircd")
+	config.LircKeymap["myremote:KEY_OK"] = "toggle"
+
+	fakeLircd(t, config.LircSocket,
+		[]string{
+			"BEGIN", "VERSION", "SUCCESS", "DATA", "1", "0.10.1", "END",
+			"0000000000f40bf0 00 KEY_UP fakeremote",
+			"0000000000f40bf0 00 KEY_VOLUMEUP fakeremote",
+			"0000000000f40bf0 01 KEY_VOLUMEUP fakeremote",
+			"0000000000f40bf0 01 KEY_OK fakeremote",
+			"0000000000f40bf0 00 KEY_UNKNOWN fakeremote",
+		},
+		[]string{
+			"0000000000f40bf0 00 KEY_OK myremote",
+			"0000000000f40bf0 00 KEY_OK fakeremote",
+		})
+
+	code := make(chan ButtonCode, 8)
+	remote := make(chan RemoteAction, 8)
+	startLircInput(code, remote)
+
+	type input struct {
+		code   ButtonCode
+		action RemoteAction
+	}
+	want := []input{
+		{code: BtnStationReForward},
+		{action: RemoteAction{Action: "volup"}},
+		{action: RemoteAction{Action: "volup", Repeat: true}},
+		// 押し続けた click は送らない。ここで切断して接続し直す
+		{action: RemoteAction{Action: "toggle"}},
+		{code: BtnStationReButton},
+	}
+	for i, w := range want {
+		var got input
+		select {
+		case got.code = <-code:
+		case got.action = <-remote:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("input %d: timed out", i)
+		}
+		if got != w {
+			t.Errorf("input %d: %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestParseLircLine(t *testing.T) {
+	k, ok := parseLircLine("0000000000f40bf0 1a KEY_VOLUMEUP myremote")
+	if !ok || k != (LircKey{Remote: "myremote", Button: "KEY_VOLUMEUP", Repeat: 0x1a}) {
+		t.Errorf("%+v %v", k, ok)
+	}
+	if _, ok := parseLircLine("BEGIN"); ok {
+		t.Error("parsed a reply line")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// radioState、infomation、keymap 等の状態は main の select ループだけが変える。
+// 入力や mpv の応答、選局の結果は各 goroutine からチャネルで送り、タイマーはチャネルを
+// ループで待つ（time.AfterFunc は使わない）。mpv を止めるのも mpvctl.Stop ではなく
+// ループから mpvStop で行う。
+
+// fetchResult 裏で行った問い合わせの結果を反映する処理。seq が古いものは取り消されたものとして捨てる
+type fetchResult struct {
+	seq   int
+	apply func()
+}
+
+var (
+	fetchDone    = make(chan fetchResult)
+	fetchSeq     int
+	fetchCancel  context.CancelFunc = func() {}
+	fetchPending bool
+)
+
+// fetch 局の検索や番組の回の一覧の様に時間のかかる問い合わせを裏で行う。
+// f は ctx の期限までに結果を反映する処理を返し、ループが fetchCompleted で実行する。
+// 問い合わせは一度に1つで、新しく始めると前のものは取り消す。
+func fetch(timeout time.Duration, f func(ctx context.Context) func()) {
+	cancelFetch()
+	seq := fetchSeq
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	fetchCancel = cancel
+	fetchPending = true
+	go func() {
+		apply := f(ctx)
+		fetchDone <- fetchResult{seq: seq, apply: apply}
+	}()
+}
+
+// cancelFetch 問い合わせ中であれば取り消す
+func cancelFetch() {
+	fetchCancel()
+	fetchSeq++
+	fetchPending = false
+}
+
+// fetchCompleted 問い合わせの結果を反映する
+func fetchCompleted(r fetchResult) {
+	if r.seq != fetchSeq {
+		// 取り消された問い合わせ
+		return
+	}
+	fetchCancel()
+	fetchPending = false
+	r.apply()
+}
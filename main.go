@@ -1,11 +1,13 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"github.com/davecheney/i2c"
-	"github.com/sakaisatoru/go_mpvradio/netradio"
 	"github.com/sakaisatoru/go_radio_raspi/mpvctl"
 	"github.com/stianeikeland/go-rpio/v4"
 	"local.packages/aqm0802a"
+	"local.packages/irremote"
 	"local.packages/rotaryencoder"
 	"local.packages/volume"
 	"log"
@@ -34,6 +36,11 @@ const (
 	BtnStationReButtonLong
 	BtnStationReButtonRepeat
 	btnSystemShutdown
+	BtnStationReDoubleClick  // ダブルクリック
+	BtnStationReHoldForward  // ボタンを押したまま右回転
+	BtnStationReHoldBackward // ボタンを押したまま左回転
+	btnStationReButtonDown   // ボタンを押した（ジェスチャーの判定用で Dispatch へは送らない）
+	btnStationReButtonUp     // ボタンを離した（同上）
 
 	BtnStationRepeat = 0x80
 
@@ -49,6 +56,15 @@ const (
 	ErrorTuning
 	ErrorRpioNotOpen
 	ErrorSocketNotOpen
+	ErrorDNS
+	ErrorHTTPClient
+	ErrorHTTPServer
+	ErrorAuth
+	ErrorTimeout
+	ErrorNoAudio
+	ErrorReconnect
+	ErrorSearch
+	ErrorNoEpisode
 )
 
 const (
@@ -64,14 +80,14 @@ const (
 )
 
 var (
-	mpv         net.Conn
-	mu          sync.Mutex
-	lcd         *aqm0802a.AQM0802A
-	radikoproxy *netradio.RadikoProxy
-	radioState  *RadioState
-	infomation  *InfomationDisplay
-	colon       uint8
-	errmessage  = [...]string{
+	mpv           net.Conn
+	mu            sync.Mutex
+	lcd           LCD
+	stationHealth *HealthChecker
+	radioState    *RadioState
+	infomation    *InfomationDisplay
+	colon         uint8
+	errmessage    = [...]string{
 		"HUP     ",   // HUP
 		"mpv ｴﾗｰ  ",  //
 		"mpv ﾌｫﾙﾄ ",  //
@@ -79,8 +95,23 @@ var (
 		"tuneｴﾗｰ  ",  //
 		"rpioｴﾗｰ  ",  //
 		"ｿｹｯﾄｴﾗｰ   ", //
+		"DNSｴﾗｰ   ",  // 名前解決の失敗
+		"HTTP4xx ",   //
+		"HTTP5xx ",   //
+		"ﾆﾝｼｮｳｴﾗｰ",   // 認証の失敗
+		"ﾀｲﾑｱｳﾄ   ",  //
+		"ﾑｵﾝ      ",  // 音が出ない
+		"ｻｲｾﾂｿﾞｸ  ",  // 配信が途切れたので再接続する
+		"ｹﾝｻｸｴﾗｰ ",   // 局の検索の失敗
+		"ｶｲｶﾞﾅｲ  ",   // オンデマンドの番組に配信されている回が無い
 	}
 
+	replayFile   = flag.String("replay", "", "入力の記録を再生して状態と画面を表示する")
+	scenarioGlob = flag.String("scenario", "", "台本（glob）を実行して画面や状態を期待と比べる")
+	configPath   = flag.String("config", configFile, "設定ファイル")
+	stationsPath = flag.String("stations", stationListFile, "局リスト")
+	dotExport    = flag.Bool("dot", false, "状態遷移図を Graphviz の DOT で書き出す")
+
 	jst      *time.Location = time.FixedZone("JST", 9*60*60)
 	voltable                = []int8{0, 15, 20, 25, 31, 37, 43, 49, 57, 63, 68}
 )
@@ -97,6 +128,7 @@ func btninput(code chan<- ButtonCode) {
 				// 押されているボタンがあれば、そのコードを保存する
 				btn_h = BtnStationReButton
 				hold = 0
+				code <- btnStationReButtonDown
 			}
 		} else {
 			// もし過去に押されていたら、現在それがどうなっているか調べる
@@ -116,6 +148,7 @@ func btninput(code chan<- ButtonCode) {
 					lcd.OneShotLight()
 					code <- btn_h // ワンショット入力
 				}
+				code <- btnStationReButtonUp
 				btn_h = 0
 				hold = 0
 			}
@@ -124,11 +157,23 @@ func btninput(code chan<- ButtonCode) {
 }
 
 func afamp_enable() {
-	rpio.Pin(pinAfAmp).High()
+	pinWrite(pinAfAmp, rpio.High)
 }
 
 func afampDisable() {
-	rpio.Pin(pinAfAmp).Low()
+	pinWrite(pinAfAmp, rpio.Low)
+}
+
+// connectStop mpv を止める時の後始末
+func connectStop() bool {
+	CancelTune()
+	if radioState.IsMuted() {
+		radioState.SetMute(false)
+	}
+	infomation.ShowError(Space8)
+	afampDisable() // AF amp disable
+	radioState.RadioDisable()
+	return false
 }
 
 func shutdown() {
@@ -139,6 +184,31 @@ func shutdown() {
 }
 
 func main() {
+	flag.Parse()
+	if *dotExport {
+		if err := exportDot(os.Stdout); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *scenarioGlob != "" {
+		// 台本もハードウェアを使わずに実行する
+		if err := runScenarios(*scenarioGlob, os.Stdout); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *replayFile != "" {
+		// 記録の再生はハードウェアを使わずに行う
+		if err := replay(*replayFile, os.Stdout); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// GPIO initialize
 	var firsterror error
 	for i := 0; i < 15; i++ {
@@ -202,14 +272,6 @@ func main() {
 	}
 	mpvctl.SetVoltable(&voltable)
 
-	// mpvctl.Stop() のコールバック関数
-	mpvctl.Cb_connect_stop = func() bool {
-		infomation.ShowError(Space8)
-		afampDisable() // AF amp disable
-		radioState.RadioDisable()
-		return false
-	}
-
 	// 音量調整
 	volume.Set(mpvctl.VolumeMax / 3)
 
@@ -218,15 +280,51 @@ func main() {
 	signal.Notify(signals, syscall.SIGTERM, syscall.SIGQUIT,
 		syscall.SIGHUP, syscall.SIGINT)
 
+	// 設定ファイル
+	config, err = LoadConfig(*configPath)
+	if err != nil {
+		log.Println(err)
+	}
+	applyConfig()
+
 	// 局リストの準備
-	if err := radioState.ReadStationListInfo(stationListFile); err != nil {
+	if err := radioState.ReadStationListInfo(*stationsPath); err != nil {
 		infomation.ShowError(ErrorHup)
 		log.Println(err)
 		return
 	}
 
-	// radiko用代理サーバー
-	radikoproxy = netradio.RadikoProxyNew()
+	// plugin:（radiko用代理サーバー等）の登録
+	registerResolvers()
+
+	// 操作の登録と入力への割り当て
+	registerActions()
+	keymap, err = KeymapNew(config.Keymap)
+	if err != nil {
+		log.Println(err)
+	}
+	irmap, err = LoadIRMap(config.IRKeymapFile)
+	if err != nil {
+		log.Println(err)
+	}
+
+	// 入力の記録
+	if config.JournalFile != "" {
+		journal, err = JournalOpen(config.JournalFile, int64(config.JournalMaxSize)*1024, config.JournalBackups)
+		if err != nil {
+			log.Println(err)
+		}
+		defer journal.Close()
+	}
+
+	// 局の死活確認
+	stationHealth = HealthCheckerNew(
+		time.Duration(config.HealthCheckInterval)*time.Minute,
+		time.Duration(config.HealthCheckTimeout)*time.Second)
+	if config.HealthCheckInterval > 0 {
+		stationHealth.SetStations(radioState.stationList)
+		go stationHealth.Loop()
+	}
 
 	// mpv socket
 	if mpvctl.Open() != nil {
@@ -242,30 +340,59 @@ func main() {
 	}()
 
 	mpvret := make(chan string)
-	// mpvからの応答を選別するフィルタ
+	mpvprop := make(chan mpvctl.MpvIRC)
+	// mpvからの応答を選別するフィルタ。別の goroutine で動くので radioState には触れず、
+	// ラジオが入っているかどうかは main のループで調べる
 	go mpvctl.Recv(mpvret, func(ms mpvctl.MpvIRC) (string, bool) {
-		if radioState.IsRadioEnable() {
-			if ms.Event == "property-change" {
-				if ms.Name == "metadata/by-key/icy-title" {
-					return ms.Data, true
+		if ms.Event == "property-change" {
+			switch ms.Name {
+			case "metadata/by-key/icy-title":
+				return ms.Data, true
+			case "media-title", "metadata/by-key/artist", "audio-codec-name":
+				mpvprop <- ms
+			default:
+				if IsStallProperty(ms.Name) {
+					mpvprop <- ms
 				}
 			}
+		} else if ms.Request_id == mpvRequestPlayPos {
+			mpvprop <- ms
 		}
 		return "", false
 	})
 
-	mpvctl.Setvol(volume.Get())
-	s := "{ \"command\": [\"observe_property_string\", 1, \"metadata/by-key/icy-title\"] }"
-	mpvctl.Send(s)
+	// 再生が止まった理由は別の接続で end-file から読む
+	mpvstopped := make(chan error)
+	go mpvStopLoop(MpvSocketPath, mpvstopped)
+
+	mpvSetvol(volume.Get())
+	s := "{ \"command\": [\"observe_property_string\", 1, \"metadata/by-key/icy-title\"] }\x0a"
+	mpvSend(s)
+	s = "{ \"command\": [\"observe_property_string\", 3, \"media-title\"] }\x0a"
+	mpvSend(s)
+	s = "{ \"command\": [\"observe_property_string\", 4, \"metadata/by-key/artist\"] }\x0a"
+	mpvSend(s)
+	s = "{ \"command\": [\"observe_property_string\", 5, \"audio-codec-name\"] }\x0a"
+	mpvSend(s)
+	// 配信の途切れの検出用
+	for i, p := range []string{"core-idle", "paused-for-cache", "eof-reached", "demuxer-cache-duration"} {
+		s = fmt.Sprintf("{ \"command\": [\"observe_property_string\", %d, \"%s\"] }\x0a", i+6, p)
+		mpvSend(s)
+	}
 	colon = 0
 
 	colonblink := time.NewTicker(500 * time.Millisecond)
 
 	// 入力受付起動
 	btncode := make(chan ButtonCode)
-	go btninput(btncode)
-	btnREcode := make(chan rotaryencoder.REvector)
-	go rencoder.DetectLoop(btnREcode)
+	btnREcode := make(chan rotaryencoder.Step)
+	startInput(btncode, btnREcode, &rencoder)
+	gesture := GestureNew()
+	ircode := make(chan irremote.Code)
+	startIRInput(ircode)
+	remote := make(chan RemoteAction)
+	startLircInput(btncode, remote)
+	startEvdevInput(btncode, remote)
 
 	radioState.GreenOn()
 	defer afampDisable()
@@ -280,22 +407,93 @@ func main() {
 
 		case title := <-mpvret:
 			// mpv の応答でフィルタで処理された文字列をここで処理する
+			if !radioState.IsRadioEnable() {
+				break
+			}
 			stmp := radioState.CurrentStationName()
 			if title != "" {
 				stmp = stmp + "  " + title
 			}
 			infomation.Update(0, stmp)
 
+		case r := <-tuneDone:
+			// 非同期に求めた再生URL
+			tuneCompleted(r)
+
+		case r := <-fetchDone:
+			// 裏で行った局の検索や回の一覧の読み込み
+			fetchCompleted(r)
+
+		case <-tuneRetryTimer.C:
+			// 待ち時間を置いて選局をやり直す
+			tuneRetry()
+
+		case <-radioState.restoreTimer.C:
+			// 選局を確定しないまま時間が経った
+			radioState.restoreStation()
+
+		case err := <-mpvstopped:
+			// 再生中に mpv が待機状態へ戻った（エラーあるいは配信の終了）
+			if radioState.IsRadioEnable() {
+				tuneStopped(err)
+			}
+
+		case ms := <-mpvprop:
+			if !radioState.IsRadioEnable() {
+				break
+			}
+			switch {
+			case ms.Request_id == mpvRequestPlayPos:
+				radioState.SetPlayPos(ms.Data)
+			case ms.Name == "audio-codec-name" && ms.Data != "":
+				// 音が出始めた
+				tuneAudioStarted()
+			case IsStallProperty(ms.Name):
+				streamStall.Update(ms.Name, ms.Data, timeNow())
+			case ms.Name == "media-title" || ms.Name == "metadata/by-key/artist":
+				// 手元の音声ファイルは曲のタグを icy-title と同じ様に表示する
+				if radioState.IsPlaylist() {
+					stmp := radioState.CurrentStationName()
+					if t := radioState.SetTrackTag(ms.Name, ms.Data); t != "" {
+						stmp = stmp + "  " + t
+					}
+					infomation.Update(0, stmp)
+				}
+			}
+
 		case <-colonblink.C:
 			colon ^= 1
 			infomation.ShowClock(radioState.GetStateString(colon))
 			radioState.TokeiCheck()
+			radioState.IdleCheck()
+			radioState.ErrorIndicate(colon)
+			tuneCheckAudio()
+			tuneCheckStall()
+			if radioState.GetState() == statePlayback || radioState.IsTrackSkip() {
+				requestPlayPos()
+			}
 
 		case r := <-btnREcode:
-			radioState.Dispatch(ButtonCode(r))
+			gesture.Rotate(r)
+
+		case <-gesture.C():
+			// ダブルクリックにならなかった
+			if gesture.Timeout() {
+				return
+			}
+
+		case r := <-ircode:
+			if radioState.RunIR(r) {
+				return
+			}
+
+		case r := <-remote:
+			if radioState.RunRemote(r.Action, r.Repeat) {
+				return
+			}
 
 		case r := <-btncode:
-			if radioState.Dispatch(r) {
+			if gesture.Button(r) {
 				// 処理中断で終了する。defer を生かすため return で終わる。
 				return
 			}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MenuEditor 設定値の編集。Begin で今の値を読み、Step で動かし、Next が true を返したら
+// Commit で書き込む。時刻のように桁が分かれているものは Next で次の桁へ移る。
+// 編集していない時は Value で今の値を表示する。Value は編集中の値に触れない。
+type MenuEditor interface {
+	Begin()
+	Step(d int)
+	Next() bool
+	Commit()
+	String(blink bool) string // 2行目に表示する編集中の値（8文字）。blink が true なら編集中の桁を消す
+	Value() string            // 2行目に表示する今の設定値（8文字）
+}
+
+// MenuItem メニューの項目。Items があれば下の階層、無ければ Editor で値を編集する。
+// Key は設定ファイルに書き込む項目の名前。
+type MenuItem struct {
+	Label  string
+	Key    string
+	Items  []*MenuItem
+	Editor MenuEditor
+}
+
+// menuLevel メニューの階層と選んでいる項目
+type menuLevel struct {
+	items []*MenuItem
+	pos   int
+}
+
+// Menu 1行目に項目名、2行目に値を表示して、ロータリーエンコーダで辿るメニュー
+type Menu struct {
+	stack   []menuLevel
+	editing bool
+}
+
+// Open 最上位の階層から始める
+func (m *Menu) Open(root []*MenuItem) {
+	m.stack = []menuLevel{{items: root}}
+	m.editing = false
+}
+
+// Close 編集中の値を捨てて閉じる
+func (m *Menu) Close() {
+	m.stack = nil
+	m.editing = false
+}
+
+// Current 選んでいる項目を返す
+func (m *Menu) Current() *MenuItem {
+	l := &m.stack[len(m.stack)-1]
+	return l.items[l.pos]
+}
+
+// IsTop 最上位の階層で、値を編集していなければ true を返す
+func (m *Menu) IsTop() bool {
+	return len(m.stack) <= 1 && !m.editing
+}
+
+// IsEditing 値を編集中かどうかを返す
+func (m *Menu) IsEditing() bool {
+	return m.editing
+}
+
+// Step 編集中なら値を、そうでなければ選ぶ項目を d だけ動かす（項目は端で折り返す）
+func (m *Menu) Step(d int) {
+	if m.editing {
+		m.Current().Editor.Step(d)
+		return
+	}
+	l := &m.stack[len(m.stack)-1]
+	l.pos = (l.pos + d + len(l.items)) % len(l.items)
+}
+
+// Select 下の階層へ入るか値の編集を始める。編集中なら次の桁へ移り、編集を終えたら
+// 値を書き込んで項目を返す。
+func (m *Menu) Select() *MenuItem {
+	it := m.Current()
+	switch {
+	case m.editing:
+		if !it.Editor.Next() {
+			return nil
+		}
+		it.Editor.Commit()
+		m.editing = false
+		return it
+	case len(it.Items) > 0:
+		m.stack = append(m.stack, menuLevel{items: it.Items})
+	case it.Editor != nil:
+		it.Editor.Begin()
+		m.editing = true
+	}
+	return nil
+}
+
+// Back 編集中なら値を捨て、そうでなければ上の階層へ戻る
+func (m *Menu) Back() {
+	if m.editing {
+		m.editing = false
+		return
+	}
+	if len(m.stack) > 1 {
+		m.stack = m.stack[:len(m.stack)-1]
+	}
+}
+
+// Label 1行目に表示する項目名
+func (m *Menu) Label() string {
+	return m.Current().Label
+}
+
+// String 2行目に表示する値。下の階層がある項目には印を表示する。
+func (m *Menu) String(blink bool) string {
+	it := m.Current()
+	switch {
+	case len(it.Items) > 0:
+		return fmt.Sprintf("%8s", ">>")
+	case it.Editor == nil:
+		return strings.Repeat(" ", 8)
+	case !m.editing:
+		return it.Editor.Value()
+	}
+	return it.Editor.String(blink)
+}
+
+// menuValue 値を右に寄せて8文字にする。blink なら空白にする
+func menuValue(s string, blink bool) string {
+	if blink {
+		return strings.Repeat(" ", 8)
+	}
+	return fmt.Sprintf("%8.8s", s)
+}
+
+// NumberEditor Min から Max までの整数を Inc 刻みで編集する。
+// Zero が空でなければ 0 をその文字で表示する（「無し」等）。
+type NumberEditor struct {
+	Get  func() int
+	Set  func(int)
+	Min  int
+	Max  int
+	Inc  int
+	Unit string
+	Zero string
+	val  int
+}
+
+func (e *NumberEditor) Begin() {
+	e.val = e.Get()
+}
+
+func (e *NumberEditor) Step(d int) {
+	e.val = min(max(e.val+d*e.Inc, e.Min), e.Max)
+}
+
+func (e *NumberEditor) Next() bool {
+	return true
+}
+
+func (e *NumberEditor) Commit() {
+	e.Set(e.val)
+}
+
+func (e *NumberEditor) String(blink bool) string {
+	return menuValue(e.format(e.val), blink)
+}
+
+func (e *NumberEditor) Value() string {
+	return menuValue(e.format(e.Get()), false)
+}
+
+func (e *NumberEditor) format(n int) string {
+	if n == 0 && e.Zero != "" {
+		return e.Zero
+	}
+	return fmt.Sprintf("%d%s", n, e.Unit)
+}
+
+// EnumOption 選択肢。Label は表示（8文字以内）、Value は設定ファイルに書く値
+type EnumOption struct {
+	Label string
+	Value string
+}
+
+// EnumEditor 選択肢から選ぶ（端で折り返す）。今の値が選択肢に無ければ先頭から始める。
+type EnumEditor struct {
+	Get     func() string
+	Set     func(string)
+	Options []EnumOption
+	pos     int
+}
+
+func (e *EnumEditor) Begin() {
+	e.pos = e.find(e.Get())
+}
+
+// find 値の選択肢の位置を返す。無ければ先頭とする
+func (e *EnumEditor) find(v string) int {
+	for i, o := range e.Options {
+		if o.Value == v {
+			return i
+		}
+	}
+	return 0
+}
+
+func (e *EnumEditor) Step(d int) {
+	e.pos = (e.pos + d + len(e.Options)) % len(e.Options)
+}
+
+func (e *EnumEditor) Next() bool {
+	return true
+}
+
+func (e *EnumEditor) Commit() {
+	e.Set(e.Options[e.pos].Value)
+}
+
+func (e *EnumEditor) String(blink bool) string {
+	return menuValue(e.Options[e.pos].Label, blink)
+}
+
+func (e *EnumEditor) Value() string {
+	return menuValue(e.Options[e.find(e.Get())].Label, false)
+}
+
+// BoolEditor on/off を切り替える
+type BoolEditor struct {
+	Get func() bool
+	Set func(bool)
+	val bool
+}
+
+func (e *BoolEditor) Begin() {
+	e.val = e.Get()
+}
+
+func (e *BoolEditor) Step(d int) {
+	e.val = !e.val
+}
+
+func (e *BoolEditor) Next() bool {
+	return true
+}
+
+func (e *BoolEditor) Commit() {
+	e.Set(e.val)
+}
+
+func (e *BoolEditor) String(blink bool) string {
+	return menuValue(boolLabel(e.val), blink)
+}
+
+func (e *BoolEditor) Value() string {
+	return menuValue(boolLabel(e.Get()), false)
+}
+
+func boolLabel(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// TimeEditor 時刻（時と分）を編集する。時、分の順に Next で移る。
+type TimeEditor struct {
+	Get    func() (int, int)
+	Set    func(int, int)
+	hour   int
+	minute int
+	field  int // 0 時 1 分
+}
+
+func (e *TimeEditor) Begin() {
+	e.hour, e.minute = e.Get()
+	e.field = 0
+}
+
+func (e *TimeEditor) Step(d int) {
+	if e.field == 0 {
+		e.hour = (e.hour + d + 24) % 24
+	} else {
+		e.minute = (e.minute + d + 60) % 60
+	}
+}
+
+func (e *TimeEditor) Next() bool {
+	e.field++
+	return e.field > 1
+}
+
+func (e *TimeEditor) Commit() {
+	e.Set(e.hour, e.minute)
+}
+
+func (e *TimeEditor) String(blink bool) string {
+	h := fmt.Sprintf("%02d", e.hour)
+	m := fmt.Sprintf("%02d", e.minute)
+	if blink {
+		// 編集中の桁を点滅させる
+		if e.field == 0 {
+			h = "  "
+		} else {
+			m = "  "
+		}
+	}
+	return fmt.Sprintf("%8s", h+":"+m)
+}
+
+func (e *TimeEditor) Value() string {
+	h, m := e.Get()
+	return fmt.Sprintf("%8s", fmt.Sprintf("%02d:%02d", h, m))
+}
@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+// beginCounter Begin を呼んだ回数を数える
+type beginCounter struct {
+	NumberEditor
+	begins int
+}
+
+func (e *beginCounter) Begin() {
+	e.begins++
+	e.NumberEditor.Begin()
+}
+
+// 表示は編集中の値に触れず、編集していない時は今の設定値を表示する
+func TestMenuString(t *testing.T) {
+	n := 30
+	e := &beginCounter{NumberEditor: NumberEditor{
+		Get: func() int { return n }, Set: func(v int) { n = v },
+		Min: 0, Max: 60, Inc: 5, Unit: "s", Zero: "off",
+	}}
+	var m Menu
+	m.Open([]*MenuItem{{Label: "ﾗｲﾄ", Editor: e}})
+
+	if got := m.String(false); got != "     30s" || e.begins != 0 {
+		t.Errorf("%q, %d begins", got, e.begins)
+	}
+	m.Select()
+	m.Step(1)
+	if got := m.String(false); got != "     35s" {
+		t.Errorf("editing %q", got)
+	}
+	m.Back()
+	n = 0
+	if got := m.String(false); got != "     off" || e.begins != 1 {
+		t.Errorf("%q, %d begins after back", got, e.begins)
+	}
+}
+
+func TestMenuEditorValue(t *testing.T) {
+	tz := "Europe/Berlin"
+	for _, c := range []struct {
+		e    MenuEditor
+		want string
+	}{
+		{&EnumEditor{Get: func() string { return tz }, Options: settingsTimezones}, "  Berlin"},
+		{&EnumEditor{Get: func() string { return "Mars/Olympus" }, Options: settingsTimezones}, "   Tokyo"},
+		{&BoolEditor{Get: func() bool { return true }}, "      on"},
+		{&TimeEditor{Get: func() (int, int) { return 6, 5 }}, "   06:05"},
+	} {
+		if got := c.e.Value(); got != c.want {
+			t.Errorf("%T: %q, want %q", c.e, got, c.want)
+		}
+	}
+}
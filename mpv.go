@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sakaisatoru/go_radio_raspi/mpvctl"
+	"local.packages/volume"
+	"time"
+)
+
+var (
+	// mpv へ命令を送る。記録の再生では送らずに捨てる
+	mpvSend = mpvctl.Send
+
+	// 時刻を返す。記録の再生や台本では偽物の時計の時刻を返す。状態に関わる時刻は全てこれで読む
+	timeNow = time.Now
+)
+
+func init() {
+	volume.Setvol = mpvSetvol
+}
+
+// mpvStop mpvctl.Stop と同じく停止時の処理を行ってから mpv を止める。main のループから呼ぶ
+func mpvStop() error {
+	connectStop()
+	return mpvSend("{\"command\": [\"stop\"]}\x0a")
+}
+
+// mpvLoadfile mpv に URL を読み込ませる。URL に " や \ が含まれても崩れない様に JSON に直して送る
+func mpvLoadfile(s string) error {
+	b, err := json.Marshal(map[string][]string{"command": {"loadfile", s}})
+	if err != nil {
+		return err
+	}
+	return mpvSend(string(b) + "\x0a")
+}
+
+// mpvSetvol 音量を設定する。音量は voltable で mpv の音量に直す
+func mpvSetvol(vol int8) error {
+	vol = min(max(vol, mpvctl.VolumeMin), mpvctl.VolumeMax)
+	return mpvSend(fmt.Sprintf("{\"command\": [\"set_property\",\"volume\",%d]}\x0a", voltable[vol]))
+}
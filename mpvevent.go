@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	mpvEventRetryWait time.Duration = 1 * time.Second // mpv へ接続し直すまでの待ち時間
+)
+
+// mpvEvent mpv のイベントのうち mpvctl.MpvIRC では読めない項目を持つもの
+type mpvEvent struct {
+	Event     string `json:"event"`
+	Name      string `json:"name"`
+	Data      any    `json:"data"`
+	Reason    string `json:"reason"`     // end-file: eof、stop、quit、error、redirect
+	FileError string `json:"file_error"` // end-file: reason が error の時の理由
+	Level     string `json:"level"`      // log-message
+	Text      string `json:"text"`       // log-message
+}
+
+// MpvEndFileError mpv が再生を終えた理由。読み込みに失敗した時は直前のエラーのログを添える
+type MpvEndFileError struct {
+	Reason    string
+	FileError string
+	Log       string
+}
+
+func (e *MpvEndFileError) Error() string {
+	s := "mpv: end-file " + e.Reason
+	if e.FileError != "" {
+		s += " (" + e.FileError + ")"
+	}
+	if e.Log != "" {
+		s += ": " + e.Log
+	}
+	return s
+}
+
+var (
+	// ffmpeg のログから HTTP のステータスコードを取り出す
+	mpvHTTPErrorLog = regexp.MustCompile(`HTTP error (\d{3})`)
+)
+
+// classifyEndFile mpv が再生を終えた理由を選局の失敗の種類に分ける
+func classifyEndFile(e *MpvEndFileError) (TuneErrorKind, int) {
+	if e.Reason != "error" {
+		// 配信が終わった
+		return tuneErrStall, 0
+	}
+	l := strings.ToLower(e.Log)
+	switch {
+	case strings.Contains(l, "failed to resolve hostname"), strings.Contains(l, "name or service not known"),
+		strings.Contains(l, "temporary failure in name resolution"):
+		return tuneErrDNS, 0
+	case strings.Contains(l, "timed out"):
+		return tuneErrTimeout, 0
+	}
+	if m := mpvHTTPErrorLog.FindStringSubmatch(e.Log); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return tuneErrUnknown, code
+	}
+	switch e.FileError {
+	case "unrecognized file format", "no audio or video data played":
+		return tuneErrNoAudio, 0
+	}
+	return tuneErrUnknown, 0
+}
+
+// mpvStopLoop mpv に別に接続し、待機状態に戻る度に直前の end-file を stopped へ送る。
+// end-file の理由は mpvctl.MpvIRC に無いので、待機状態への変化とあわせてこの接続で読む。
+// 同じ接続で読むことで end-file と待機状態の順序が保たれる。
+func mpvStopLoop(path string, stopped chan<- error) {
+	for {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			time.Sleep(mpvEventRetryWait)
+			continue
+		}
+		fmt.Fprint(conn, "{\"command\": [\"request_log_messages\", \"error\"]}\x0a")
+		fmt.Fprint(conn, "{\"command\": [\"observe_property_string\", 1, \"idle-active\"]}\x0a")
+		mpvStopRead(conn, stopped)
+		conn.Close()
+		log.Println("mpv: イベントの接続が切れました")
+		time.Sleep(mpvEventRetryWait)
+	}
+}
+
+// mpvStopRead 接続が切れるまでイベントを読む
+func mpvStopRead(conn net.Conn, stopped chan<- error) {
+	var (
+		lastLog string
+		ended   error
+	)
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var ev mpvEvent
+		if json.Unmarshal(scanner.Bytes(), &ev) != nil {
+			continue
+		}
+		switch ev.Event {
+		case "start-file":
+			lastLog = ""
+			ended = nil
+		case "log-message":
+			if ev.Level == "error" || ev.Level == "fatal" {
+				lastLog = strings.TrimSpace(ev.Text)
+			}
+		case "end-file":
+			if ev.Reason == "stop" || ev.Reason == "quit" || ev.Reason == "redirect" {
+				// 止めたか、別のURLを読み込んだ
+				ended = nil
+				continue
+			}
+			ended = &MpvEndFileError{Reason: ev.Reason, FileError: ev.FileError, Log: lastLog}
+		case "property-change":
+			if ev.Name == "idle-active" && ev.Data == "yes" {
+				stopped <- ended
+				ended = nil
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	nhkConfigLifetime time.Duration = 24 * time.Hour
+	nhkConfigTimeout  time.Duration = 10 * time.Second
+)
+
+type nhkStreamData struct {
+	AreaJP  string `xml:"areajp"`
+	Area    string `xml:"area"`
+	AreaKey string `xml:"areakey"`
+	R1hls   string `xml:"r1hls"`
+	R2hls   string `xml:"r2hls"`
+	FMhls   string `xml:"fmhls"`
+}
+
+type nhkConfig struct {
+	XMLName xml.Name        `xml:"radiru_config"`
+	Data    []nhkStreamData `xml:"stream_url>data"`
+}
+
+// nhkResolver plugin:/nhk/<地域>/<r1|r2|fm> らじる★らじるの HLS のURLを設定XMLから求める
+type nhkResolver struct {
+	mu        sync.Mutex
+	configURL string
+	cacheFile string // 設定XMLのキャッシュ。URLごとに分ける
+	config    *nhkConfig
+	fetched   time.Time
+	client    *http.Client
+}
+
+// nhkResolverNew 設定XMLを configURL から読み、cacheDir にキャッシュする
+func nhkResolverNew(configURL, cacheDir string) *nhkResolver {
+	h := sha256.Sum256([]byte(configURL))
+	return &nhkResolver{
+		configURL: configURL,
+		cacheFile: filepath.Join(cacheDir, "nhk_config_"+hex.EncodeToString(h[:6])+".xml"),
+		client:    &http.Client{Timeout: nhkConfigTimeout},
+	}
+}
+
+func (r *nhkResolver) Name() string {
+	return "nhk"
+}
+
+func (r *nhkResolver) IsCheap() bool {
+	// 設定XMLはキャッシュしているので問い合わせは1日1回程度
+	return true
+}
+
+func (r *nhkResolver) Resolve(ctx context.Context, arg string) (Resolved, error) {
+	area, channel, ok := strings.Cut(arg, "/")
+	if !ok {
+		return Resolved{}, fmt.Errorf("nhk: %s 地域とチャンネルを指定してください", arg)
+	}
+	c, err := r.loadConfig(ctx)
+	if err != nil {
+		return Resolved{}, err
+	}
+
+	for _, d := range c.Data {
+		if !strings.EqualFold(d.Area, area) && d.AreaJP != area && d.AreaKey != area {
+			continue
+		}
+		var u string
+		switch strings.ToLower(channel) {
+		case "r1":
+			u = d.R1hls
+		case "r2":
+			u = d.R2hls
+		case "fm":
+			u = d.FMhls
+		default:
+			return Resolved{}, fmt.Errorf("nhk: %s 不明なチャンネル", channel)
+		}
+		u = strings.TrimSpace(u)
+		if u == "" {
+			return Resolved{}, fmt.Errorf("nhk: %s/%s 配信がありません", area, channel)
+		}
+		return Resolved{URL: u}, nil
+	}
+	return Resolved{}, fmt.Errorf("nhk: %s 不明な地域", area)
+}
+
+// loadConfig 設定XMLを返す。期限内であればメモリあるいはファイルのキャッシュを使い、
+// 取得に失敗した場合は期限切れのキャッシュでも使う。
+func (r *nhkResolver) loadConfig(ctx context.Context) (*nhkConfig, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.config != nil && timeNow().Sub(r.fetched) < nhkConfigLifetime {
+		return r.config, nil
+	}
+	if r.config == nil {
+		if fi, err := os.Stat(r.cacheFile); err == nil {
+			if b, err := os.ReadFile(r.cacheFile); err == nil {
+				if c, err := parseNHKConfig(b); err == nil {
+					r.config = c
+					r.fetched = fi.ModTime()
+					if timeNow().Sub(r.fetched) < nhkConfigLifetime {
+						return r.config, nil
+					}
+				}
+			}
+		}
+	}
+
+	b, err := r.fetch(ctx)
+	if err == nil {
+		var c *nhkConfig
+		if c, err = parseNHKConfig(b); err == nil {
+			r.config = c
+			r.fetched = timeNow()
+			os.WriteFile(r.cacheFile, b, 0666)
+			return r.config, nil
+		}
+	}
+	if r.config != nil {
+		return r.config, nil
+	}
+	return nil, err
+}
+
+func (r *nhkResolver) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", r.configURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", httpUserAgent)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("nhk: %w", httpStatusError(resp))
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func parseNHKConfig(b []byte) (*nhkConfig, error) {
+	c := &nhkConfig{}
+	if err := xml.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	if len(c.Data) == 0 {
+		return nil, fmt.Errorf("nhk: 設定XMLに配信情報がありません")
+	}
+	return c, nil
+}
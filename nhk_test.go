@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"github.com/sakaisatoru/go_radio_br_zero/fixture"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// nhkFixture testdata/nhk の設定XMLを返し、問い合わせの回数を数えるサーバー
+func nhkFixture(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+	var n atomic.Int32
+	h := fixture.Handler("testdata")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.Add(1)
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/nhk/config_web.xml", &n
+}
+
+func TestNHKResolve(t *testing.T) {
+	u, _ := nhkFixture(t)
+	r := nhkResolverNew(u, t.TempDir())
+	tests := []struct {
+		arg  string
+		want string // 空ならエラー
+	}{
+		{"tokyo/r1", "/2023229/nhkradiruakr1/"},
+		{"東京/fm", "/2023507/nhkradiruakfm/"},
+		{"270/R2", "/2023501/nhkradiruakr2/"},
+		{"sapporo/fm", "/2023546/nhkradiruikfm/"},
+		{"naha/r1", ""},
+		{"tokyo/r3", ""},
+		{"tokyo", ""},
+	}
+	for _, tt := range tests {
+		rv, err := r.Resolve(context.Background(), tt.arg)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("%s: no error, resolved %s", tt.arg, rv.URL)
+		case tt.want != "" && err != nil:
+			t.Errorf("%s: %v", tt.arg, err)
+		case tt.want != "" && !strings.Contains(rv.URL, tt.want):
+			t.Errorf("%s: %s, want %s", tt.arg, rv.URL, tt.want)
+		}
+	}
+}
+
+// 設定XMLは URL ごとにキャッシュし、期限内であれば問い合わせない
+func TestNHKConfigCache(t *testing.T) {
+	now := time.Date(2026, time.July, 6, 12, 0, 0, 0, jst)
+	setGlobal(t, &timeNow, func() time.Time { return now })
+	dir := t.TempDir()
+	u, n := nhkFixture(t)
+
+	resolve := func(r *nhkResolver) {
+		t.Helper()
+		if _, err := r.Resolve(context.Background(), "tokyo/r1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := nhkResolverNew(u, dir)
+	resolve(r)
+	resolve(r)
+	// 起動し直してもファイルのキャッシュを使う
+	resolve(nhkResolverNew(u, dir))
+	if got := n.Load(); got != 1 {
+		t.Errorf("fetched %d times, want 1", got)
+	}
+
+	// 別の URL は別にキャッシュする
+	other, m := nhkFixture(t)
+	resolve(nhkResolverNew(other, dir))
+	if got := m.Load(); got != 1 {
+		t.Errorf("other URL fetched %d times, want 1", got)
+	}
+
+	// 期限が切れたら読み直す
+	now = now.Add(nhkConfigLifetime + time.Minute)
+	resolve(r)
+	if got := n.Load(); got != 2 {
+		t.Errorf("fetched %d times after expiry, want 2", got)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+const (
+	mpvRequestPlayPos int = 100 // 再生位置の問い合わせに付ける request_id
+)
+
+// requestPlayPos mpv へ再生位置を問い合わせる。応答は request_id で識別する。
+func requestPlayPos() {
+	mpvSend(fmt.Sprintf("{\"command\": [\"get_property_string\",\"time-pos\"], \"request_id\": %d}\x0a",
+		mpvRequestPlayPos))
+}
+
+// seek 再生位置を秒単位で相対的に動かす
+func seek(sec int) {
+	mpvSend(fmt.Sprintf("{\"command\": [\"seek\",%d,\"relative\"]}\x0a", sec))
+}
+
+// SetPlayPos mpv から得た再生位置を保存する
+func (v *RadioState) SetPlayPos(s string) {
+	v.playPos = s
+}
+
+// playbackStateString 回の選択中は位置/件数を、再生中は再生位置を返す
+func (v *RadioState) playbackStateString() string {
+	if v.currState == stateEpisodeSelect {
+		mark := ' '
+		if !v.episodes[v.episodePos].Played {
+			mark = '*' // 未再生
+		}
+		return fmt.Sprintf("%c%3d/%-3d", mark, v.episodePos+1, len(v.episodes))
+	}
+	if v.playPos == "" {
+		return "--:--:--"
+	}
+	return fmt.Sprintf("%-8s", v.playPos)
+}
+
+// showEpisode 選択中の回の題名を表示する
+func (v *RadioState) showEpisode() {
+	infomation.Update(0, v.episodes[v.episodePos].Title)
+}
+
+// selectEpisode 選んだ局がオンデマンドの番組であれば回の一覧を裏で読み込む。読み込めたら
+// !episodes で回の選択に移る。
+func (v *RadioState) selectEpisode() {
+	er, arg, ok := LookupEpisodeResolver(v.CurrentStationURL())
+	if !ok {
+		return
+	}
+
+	infomation.Update(0, "ﾖﾐｺﾐﾁｭｳ")
+	pos := v.pos
+	fetch(podcastTimeout, func(ctx context.Context) func() {
+		eps, err := er.Episodes(ctx, arg)
+		return func() {
+			if v.currState != stateTuneMode || v.pos != pos {
+				// 読み込み中に他の局へ回したか、選局をやめた
+				return
+			}
+			v.episodesLoaded(eps, err)
+		}
+	})
+}
+
+// episodesLoaded 読み込んだ回の一覧から最新の未再生の回を選ぶ
+func (v *RadioState) episodesLoaded(eps []Episode, err error) {
+	if err != nil {
+		log.Println(err)
+		infomation.ShowError(ErrorTuning)
+		return
+	}
+	if len(eps) == 0 {
+		log.Printf("%s: 配信されている回がありません", v.CurrentStationURL())
+		infomation.ShowError(ErrorNoEpisode)
+		return
+	}
+
+	v.episodes = eps
+	v.episodePos = 0
+	for i, e := range eps {
+		// 最新の未再生の回から始める
+		if !e.Played {
+			v.episodePos = i
+			break
+		}
+	}
+	v.Event(eventEpisodes)
+}
+
+// nextEpisode 選択中の回を d だけ動かす
+func (v *RadioState) nextEpisode(d int) {
+	v.episodePos = min(max(v.episodePos+d, 0), len(v.episodes)-1)
+	v.showEpisode()
+}
+
+// playEpisode 選んだ回を再生する
+func (v *RadioState) playEpisode() {
+	er, arg, ok := LookupEpisodeResolver(v.CurrentStationURL())
+	if !ok || len(v.episodes) == 0 {
+		v.stay = true
+		return
+	}
+	ep := &v.episodes[v.episodePos]
+	er.Select(arg, ep.GUID)
+	ep.Played = true
+	infomation.Update(0, ep.Title)
+	tuneStation()
+}
+
+// playbackStep オンデマンド番組の再生位置を動かす
+func (v *RadioState) playbackStep(d int) {
+	seek(d * config.SeekStep)
+}
+
+// trackStep 手元の音声ファイルの曲を d の向きに送る
+func (v *RadioState) trackStep(d int) {
+	if !v.playlist {
+		return
+	}
+	if d > 0 {
+		mpvSend("{\"command\": [\"playlist-next\"]}\x0a")
+	} else {
+		mpvSend("{\"command\": [\"playlist-prev\"]}\x0a")
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const emptyFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel><title>empty</title></channel></rss>`
+
+// podcastFixture testdata のフィードと回の無いフィードを返すサーバーを用意し、
+// それぞれを局リストの末尾に加える
+func podcastFixture(t *testing.T) (feed, empty int) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle("/podcast/", http.StripPrefix("/podcast/", http.FileServer(http.Dir("testdata/podcast"))))
+	mux.HandleFunc("/empty.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(emptyFeed))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	RegisterResolver(podcastResolverNew(t.TempDir()), RetryPolicy{Attempts: 1})
+	if err := radioState.AddStation("Podcast", "Fixture", "plugin:/podcast/"+srv.URL+"/podcast/rss.xml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := radioState.AddStation("Podcast", "Empty", "plugin:/podcast/"+srv.URL+"/empty.xml"); err != nil {
+		t.Fatal(err)
+	}
+	return radioState.stationListLen - 2, radioState.stationListLen - 1
+}
+
+// 回の一覧はループを止めずに裏で読み込み、読み込めたら回の選択へ移る
+func TestSelectEpisode(t *testing.T) {
+	r := testRadio(t, `{}`)
+	feed, _ := podcastFixture(t)
+
+	r.input(JournalEntry{Kind: journalAction, Action: "tunemode"})
+	radioState.pos = feed
+	radioState.selectEpisode()
+	if radioState.GetState() != stateTuneMode || !fetchPending {
+		t.Fatal("episodes were not loaded in the background")
+	}
+	r.settle()
+	if s := radioState.GetState(); s != stateEpisodeSelect {
+		t.Fatalf("state %s", stateName(s))
+	}
+	if n := len(radioState.episodes); n != 2 {
+		t.Errorf("%d episodes", n)
+	}
+	if ep := radioState.episodes[radioState.episodePos]; ep.GUID != "fixture-rss-0002" {
+		t.Errorf("selected %s", ep.GUID)
+	}
+}
+
+func TestSelectEpisodeEmpty(t *testing.T) {
+	r := testRadio(t, `{}`)
+	_, empty := podcastFixture(t)
+
+	r.input(JournalEntry{Kind: journalAction, Action: "tunemode"})
+	radioState.pos = empty
+	r.input(JournalEntry{Kind: journalAction, Action: "tune"})
+	if s := radioState.GetState(); s != stateTuneMode {
+		t.Fatalf("state %s", stateName(s))
+	}
+	if got := strings.TrimSpace(r.scr.Line(0)); got != "ｶｲｶﾞﾅｲ" {
+		t.Errorf("line0 %q", got)
+	}
+}
+
+// 読み込み中に他の局へ回したら結果は捨てる
+func TestSelectEpisodeMoved(t *testing.T) {
+	r := testRadio(t, `{}`)
+	feed, _ := podcastFixture(t)
+
+	r.input(JournalEntry{Kind: journalAction, Action: "toggle"})
+	r.input(JournalEntry{Kind: journalAction, Action: "tunemode"})
+	radioState.pos = feed
+	radioState.selectEpisode()
+	radioState.RunAction("prev")
+	select {
+	case res := <-fetchDone:
+		fetchCompleted(res)
+	case <-time.After(5 * time.Second):
+		t.Fatal("episodes did not load")
+	}
+	if s := radioState.GetState(); s != stateTuneMode {
+		t.Errorf("state %s", stateName(s))
+	}
+}
+
+// 手元の音声ファイルは音量調整の中で曲送りを行い、もう一度押すと選局へ移る
+func TestTrackSkip(t *testing.T) {
+	r := testRadio(t, `{}`)
+	var sent []string
+	setGlobal(t, &mpvSend, func(s string) error {
+		sent = append(sent, s)
+		return nil
+	})
+
+	r.input(JournalEntry{Kind: journalAction, Action: "toggle"})
+	radioState.SetOnDemand(true, true)
+	r.input(JournalEntry{Kind: journalButton, Code: "click"})
+	if s := radioState.GetState(); s != stateVolumeSet || !radioState.IsTrackSkip() {
+		t.Fatalf("state %s, track skip %v", stateName(s), radioState.IsTrackSkip())
+	}
+	sent = nil
+	r.input(JournalEntry{Kind: journalButton, Code: "forward"})
+	r.input(JournalEntry{Kind: journalButton, Code: "backward"})
+	if got := strings.Join(sent, ""); !strings.Contains(got, "playlist-next") || !strings.Contains(got, "playlist-prev") {
+		t.Errorf("sent %q", got)
+	}
+	r.input(JournalEntry{Kind: journalButton, Code: "click"})
+	if s := radioState.GetState(); s != stateTuneMode || radioState.IsTrackSkip() {
+		t.Errorf("state %s after second click", stateName(s))
+	}
+}
+
+// ポッドキャストは曲送りせずに再生位置の操作へ移る
+func TestSeekPodcast(t *testing.T) {
+	r := testRadio(t, `{}`)
+	r.input(JournalEntry{Kind: journalAction, Action: "toggle"})
+	radioState.SetOnDemand(true, false)
+	r.input(JournalEntry{Kind: journalButton, Code: "click"})
+	if s := radioState.GetState(); s != statePlayback {
+		t.Errorf("state %s", stateName(s))
+	}
+}
+
+// ディレクトリ以下の音声ファイルをパス順に並べたプレイリストを指定のファイルに書く
+func TestDirResolver(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"b/2.flac", "b/1.mp3", "a.mp3", "notes.txt", ".hidden/c.mp3"} {
+		p := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	playlist := filepath.Join(t.TempDir(), "library.m3u")
+	rv, err := dirResolverNew(playlist).Resolve(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rv.URL != playlist || !rv.Playlist || !rv.OnDemand {
+		t.Errorf("resolved %+v", rv)
+	}
+	b, err := os.ReadFile(playlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "#EXTM3U\n" + filepath.Join(dir, "a.mp3") + "\n" + filepath.Join(dir, "b/1.mp3") + "\n" + filepath.Join(dir, "b/2.flac") + "\n"
+	if string(b) != want {
+		t.Errorf("playlist:\n%s\nwant:\n%s", b, want)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"github.com/sakaisatoru/go_mpvradio/netradio"
+	"os"
+	"sync"
+	"time"
+)
+
+// afnResolver plugin:/afn.py/<局> AFN の配信URLを API で求める
+type afnResolver struct {
+}
+
+func (r *afnResolver) Name() string {
+	return "afn.py"
+}
+
+func (r *afnResolver) Resolve(ctx context.Context, arg string) (Resolved, error) {
+	u, err := netradio.AFNGetUrlWithApi(arg)
+	return Resolved{URL: u}, err
+}
+
+func (r *afnResolver) IsCheap() bool {
+	return true
+}
+
+// radikoProxy radiko の代理サーバー（netradio.RadikoProxy）
+type radikoProxy interface {
+	RadikoGetUrl(station string) error
+	GetProxyAddress() string
+	IsStop() bool
+	Start()
+}
+
+// radikoResolver plugin:/radiko.py/<局> radiko を代理サーバー経由で再生する
+type radikoResolver struct {
+	proxy radikoProxy
+	mu    sync.Mutex // 代理サーバーの局は1つなので、局の設定を1つずつ行う
+}
+
+func (r *radikoResolver) Name() string {
+	return "radiko.py"
+}
+
+// Resolve 代理サーバーに局を設定する。RadikoGetUrl は取り消せないので、取り消された選局が
+// 後から局を書き換えない様に順に行い、取り消されていれば設定しない
+func (r *radikoResolver) Resolve(ctx context.Context, arg string) (Resolved, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return Resolved{}, err
+	}
+	if err := r.proxy.RadikoGetUrl(arg); err != nil {
+		return Resolved{}, err
+	}
+	return Resolved{URL: r.proxy.GetProxyAddress()}, nil
+}
+
+func (r *radikoResolver) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.proxy.IsStop() {
+		r.proxy.Start()
+	}
+}
+
+func (r *radikoResolver) Stop() {
+	// netradio の代理サーバーは止める手段が無いので起動したままにする
+}
+
+// registerResolvers 標準の plugin を登録する
+func registerResolvers() {
+	RegisterResolver(&afnResolver{}, RetryPolicy{Attempts: 2, Wait: 1 * time.Second})
+
+	// エラーの際は認証トークンの期限切れを見越して２回再挑戦する
+	RegisterResolver(&radikoResolver{proxy: netradio.RadikoProxyNew()},
+		RetryPolicy{Attempts: 3})
+
+	RegisterResolver(nhkResolverNew(config.NHKConfigURL, os.TempDir()),
+		RetryPolicy{Attempts: 2, Wait: 2 * time.Second})
+
+	RegisterResolver(podcastResolverNew(config.PodcastCacheDir), RetryPolicy{Attempts: 1})
+
+	// 手元の音声ファイル
+	RegisterResolver(&fileResolver{}, RetryPolicy{Attempts: 1})
+	RegisterResolver(dirResolverNew(config.LibraryPlaylist), RetryPolicy{Attempts: 1})
+}
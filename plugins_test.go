@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRadikoProxy 局の設定に wait だけかかる代理サーバー
+type fakeRadikoProxy struct {
+	mu      sync.Mutex
+	station string
+	wait    map[string]time.Duration
+}
+
+func (p *fakeRadikoProxy) RadikoGetUrl(station string) error {
+	time.Sleep(p.wait[station])
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.station = station
+	return nil
+}
+
+func (p *fakeRadikoProxy) GetProxyAddress() string { return "http://127.0.0.1:9000/" }
+func (p *fakeRadikoProxy) IsStop() bool            { return false }
+func (p *fakeRadikoProxy) Start()                  {}
+
+// 取り消した選局の局の設定が遅れて終わっても、後の選局の局を書き換えない
+func TestRadikoResolveCancel(t *testing.T) {
+	proxy := &fakeRadikoProxy{wait: map[string]time.Duration{"TBS": 200 * time.Millisecond}}
+	r := &radikoResolver{proxy: proxy}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := resolveWithContext(ctx, r, "TBS")
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-done; err == nil {
+		t.Fatal("cancelled resolve succeeded")
+	}
+
+	if _, err := resolveWithContext(context.Background(), r, "QRR"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	if proxy.station != "QRR" {
+		t.Errorf("proxy station %s, want QRR", proxy.station)
+	}
+}
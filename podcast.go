@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	podcastTimeout   time.Duration = 15 * time.Second
+	podcastStateFile string        = "state.json"
+)
+
+// Episode ポッドキャストの1話分
+type Episode struct {
+	GUID      string
+	Title     string
+	URL       string
+	Published time.Time
+	Played    bool
+}
+
+type rssEnclosure struct {
+	URL string `xml:"url,attr"`
+}
+
+type rssItem struct {
+	Title     string       `xml:"title"`
+	GUID      string       `xml:"guid"`
+	PubDate   string       `xml:"pubDate"`
+	Enclosure rssEnclosure `xml:"enclosure"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	ID        string     `xml:"id"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Links     []atomLink `xml:"link"`
+}
+
+// podcastFeed RSS 2.0 と Atom のどちらも受け取れるようにしたもの
+type podcastFeed struct {
+	XMLName xml.Name
+	Items   []rssItem   `xml:"channel>item"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// podcastResolver plugin:/podcast/<フィードのURL> 選んだ回（既定では最新の回）を再生する
+type podcastResolver struct {
+	mu       sync.Mutex
+	cacheDir string
+	client   *http.Client
+	selected map[string]string          // フィード -> 選ばれた回の GUID
+	played   map[string]map[string]bool // フィード -> 再生済みの回の GUID
+}
+
+func podcastResolverNew(cacheDir string) *podcastResolver {
+	r := &podcastResolver{
+		cacheDir: cacheDir,
+		client:   &http.Client{Timeout: podcastTimeout},
+		selected: make(map[string]string),
+		played:   make(map[string]map[string]bool),
+	}
+	if b, err := os.ReadFile(filepath.Join(cacheDir, podcastStateFile)); err == nil {
+		var st map[string][]string
+		if err := json.Unmarshal(b, &st); err != nil {
+			log.Println(err)
+		}
+		for feed, guids := range st {
+			r.played[feed] = make(map[string]bool)
+			for _, g := range guids {
+				r.played[feed][g] = true
+			}
+		}
+	}
+	return r
+}
+
+func (r *podcastResolver) Name() string {
+	return "podcast"
+}
+
+func (r *podcastResolver) Resolve(ctx context.Context, feed string) (Resolved, error) {
+	eps, err := r.Episodes(ctx, feed)
+	if err != nil {
+		return Resolved{}, err
+	}
+	if len(eps) == 0 {
+		return Resolved{}, fmt.Errorf("podcast: %s 配信されている回がありません", feed)
+	}
+
+	r.mu.Lock()
+	guid := r.selected[feed]
+	delete(r.selected, feed)
+	r.mu.Unlock()
+
+	ep := eps[0]
+	for _, e := range eps {
+		if e.GUID == guid {
+			ep = e
+			break
+		}
+	}
+	r.markPlayed(feed, ep.GUID)
+	return Resolved{URL: ep.URL, OnDemand: true}, nil
+}
+
+// Select 次に再生する回を指定する
+func (r *podcastResolver) Select(feed, guid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.selected[feed] = guid
+}
+
+// Episodes フィードの回を新しい順に返す。取得できない場合はキャッシュを使う。
+func (r *podcastResolver) Episodes(ctx context.Context, feed string) ([]Episode, error) {
+	cache := filepath.Join(r.cacheDir, fmt.Sprintf("%x.xml", sha1.Sum([]byte(feed))))
+	b, err := r.fetch(ctx, feed)
+	if err == nil {
+		os.MkdirAll(r.cacheDir, 0755)
+		if e := os.WriteFile(cache, b, 0644); e != nil {
+			log.Println(e)
+		}
+	} else {
+		log.Printf("podcast: %v キャッシュを使います", err)
+		if b, err = os.ReadFile(cache); err != nil {
+			return nil, err
+		}
+	}
+
+	eps, err := parsePodcastFeed(b)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	for i := range eps {
+		eps[i].Played = r.played[feed][eps[i].GUID]
+	}
+	r.mu.Unlock()
+	return eps, nil
+}
+
+func (r *podcastResolver) fetch(ctx context.Context, feed string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", feed, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", httpUserAgent)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("podcast: %w", httpStatusError(resp))
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// markPlayed 再生済みとして記録し、ファイルに保存する
+func (r *podcastResolver) markPlayed(feed, guid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.played[feed] == nil {
+		r.played[feed] = make(map[string]bool)
+	}
+	r.played[feed][guid] = true
+
+	st := make(map[string][]string)
+	for f, guids := range r.played {
+		for g := range guids {
+			st[f] = append(st[f], g)
+		}
+		sort.Strings(st[f])
+	}
+	b, err := json.MarshalIndent(st, "", "\t")
+	if err == nil {
+		os.MkdirAll(r.cacheDir, 0755)
+		err = os.WriteFile(filepath.Join(r.cacheDir, podcastStateFile), b, 0644)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// parsePodcastFeed RSS あるいは Atom のフィードから音声のある回を取り出す
+func parsePodcastFeed(b []byte) ([]Episode, error) {
+	var (
+		f   podcastFeed
+		eps []Episode
+	)
+
+	d := xml.NewDecoder(bytes.NewReader(b))
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		// UTF-8 以外のフィードは文字コードを判定して変換する
+		t, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		s, _, err := DecodeText(t)
+		return strings.NewReader(s), err
+	}
+	if err := d.Decode(&f); err != nil {
+		return nil, err
+	}
+
+	for _, it := range f.Items {
+		if it.Enclosure.URL == "" {
+			continue
+		}
+		ep := Episode{
+			GUID:  strings.TrimSpace(it.GUID),
+			Title: strings.TrimSpace(it.Title),
+			URL:   strings.TrimSpace(it.Enclosure.URL),
+		}
+		ep.Published, _ = parsePodcastDate(it.PubDate)
+		eps = append(eps, ep)
+	}
+	for _, en := range f.Entries {
+		ep := Episode{
+			GUID:  strings.TrimSpace(en.ID),
+			Title: strings.TrimSpace(en.Title),
+		}
+		for _, l := range en.Links {
+			if l.Rel == "enclosure" {
+				ep.URL = strings.TrimSpace(l.Href)
+				break
+			}
+		}
+		if ep.URL == "" {
+			continue
+		}
+		if en.Published != "" {
+			ep.Published, _ = parsePodcastDate(en.Published)
+		} else {
+			ep.Published, _ = parsePodcastDate(en.Updated)
+		}
+		eps = append(eps, ep)
+	}
+
+	for i := range eps {
+		if eps[i].GUID == "" {
+			eps[i].GUID = eps[i].URL
+		}
+	}
+	sort.SliceStable(eps, func(i, j int) bool {
+		return eps[i].Published.After(eps[j].Published)
+	})
+	return eps, nil
+}
+
+func parsePodcastDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	var err error
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, time.RFC3339,
+		"Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST"} {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	radioBrowserLimit   int           = 50
+	radioBrowserTimeout time.Duration = 10 * time.Second
+	httpUserAgent       string        = "go_radio_br_zero/2.9"
+)
+
+// RadioBrowserStation radio-browser.info の局情報（必要な項目のみ）
+type RadioBrowserStation struct {
+	UUID        string `json:"stationuuid"`
+	Name        string `json:"name"`
+	Url         string `json:"url"`
+	UrlResolved string `json:"url_resolved"`
+	Tags        string `json:"tags"`
+	Country     string `json:"country"`
+	CountryCode string `json:"countrycode"`
+	Codec       string `json:"codec"`
+	Bitrate     int    `json:"bitrate"`
+}
+
+// StreamURL 再生に使うURLを返す
+func (s *RadioBrowserStation) StreamURL() string {
+	if s.UrlResolved != "" {
+		return s.UrlResolved
+	}
+	return s.Url
+}
+
+type RadioBrowser struct {
+	baseURL string
+	client  *http.Client
+}
+
+// RadioBrowserNew radio-browser.info 互換サーバーのクライアントを返す。
+// baseURL を差し替える事でミラーやテスト用のサーバーに接続できる。
+func RadioBrowserNew(baseURL string) *RadioBrowser {
+	return &RadioBrowser{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: radioBrowserTimeout},
+	}
+}
+
+// Search 条件に一致する局を人気順に返す
+func (rb *RadioBrowser) Search(ctx context.Context, q DirectoryQuery) ([]RadioBrowserStation, error) {
+	var stations []RadioBrowserStation
+
+	p := url.Values{}
+	if q.Tag != "" {
+		p.Set("tag", q.Tag)
+	}
+	if q.Country != "" {
+		p.Set("countrycode", q.Country)
+	}
+	if q.Name != "" {
+		p.Set("name", q.Name)
+	}
+	p.Set("hidebroken", "true")
+	p.Set("order", "clickcount")
+	p.Set("reverse", "true")
+	p.Set("limit", strconv.Itoa(radioBrowserLimit))
+
+	req, err := http.NewRequestWithContext(ctx, "GET",
+		rb.baseURL+"/json/stations/search?"+p.Encode(), nil)
+	if err != nil {
+		return stations, err
+	}
+	req.Header.Set("User-Agent", httpUserAgent)
+	resp, err := rb.client.Do(req)
+	if err != nil {
+		return stations, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return stations, fmt.Errorf("radio-browser: %w", httpStatusError(resp))
+	}
+	err = json.NewDecoder(resp.Body).Decode(&stations)
+	return stations, err
+}
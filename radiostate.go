@@ -1,9 +1,7 @@
 package main
 
 import (
-	"github.com/sakaisatoru/go_mpvradio/netradio"
-	"github.com/sakaisatoru/go_radio_raspi/mpvctl"
-	"local.packages/volume"
+	"local.packages/irremote"
 	"time"
 )
 
@@ -16,6 +14,14 @@ const (
 	stateSelectFunction                  // アラームON -> スリープON -> アラーム・スリープON -> ALL OFF
 	stateAlarmHourSet                    // アラーム時セット
 	stateAlarmMinSet                     // アラーム分セット
+	stateDirQuery                        // 局検索の条件選択
+	stateDirBrowse                       // 局検索結果の閲覧
+	stateEpisodeSelect                   // オンデマンド番組の回の選択
+	statePlayback                        // オンデマンド番組の再生位置の操作
+	stateDiagnostics                     // 選局の失敗の記録の表示
+	stateIRLearn                         // リモコンの学習
+	stateSettings                        // 設定メニュー
+	stateHistory                         // 再生した局の履歴
 )
 
 type TokeiState int
@@ -28,6 +34,7 @@ const (
 
 const (
 	stationRestoreDuration time.Duration = 5000 * time.Millisecond
+	stationAliveDuration   time.Duration = 30 * time.Second
 )
 
 type RadioState struct {
@@ -38,10 +45,41 @@ type RadioState struct {
 	radioEnable    bool
 	pos            int
 	lastpos        int
-	stationList    []netradio.StationInfo
+	stationList    []StationInfo
 	stationListLen int
+	stationPath    string
+	stationEnc     TextEncoding
 	tokeiState     TokeiState
 	restoreTimer   *time.Timer
+	tuning         bool      // 選局中（再生するURLを求めている）
+	tunePos        int       // 選局中の局
+	urlTries       int       // 現在の局で試したURLの数
+	tuneStart      time.Time // 最後にURLを読み込んだ時刻
+	audioStarted   bool      // 読み込んだURLで音が出始めたか
+	reconnecting   bool      // 途切れた配信へ再接続中
+	errorBlink     bool      // LED でエラーを表示中
+	dirQueryPos    int
+	dirResults     []RadioBrowserStation
+	dirPos         int
+	episodes       []Episode
+	episodePos     int
+	onDemand       bool   // 再生中のものが終わりのある番組か
+	playlist       bool   // 再生中のものが曲送りのできるものか
+	trackSkip      bool   // 音量調整の中で曲送りをしているか
+	playPos        string // オンデマンド番組の再生位置
+	trackTitle     string // 再生中の曲の題名
+	trackArtist    string // 再生中の曲の演者
+	diagPos        int
+	irPages        []string      // 学習画面で選べる操作
+	irPos          int           // 学習画面で選んでいる操作
+	irLearned      irremote.Code // 学習画面で今覚えたボタン
+	menu           Menu          // 設定メニュー
+	history        []int         // 再生した局の order（新しい順）
+	historyPos     int           // 履歴で選んでいる局
+	muted          bool
+	quit           bool      // 操作の結果プログラムを終える
+	stay           bool      // 操作が成り立たなかったので遷移しない
+	idleUntil      time.Time // 入力が無ければ入力待ちへ戻る時刻
 }
 
 func RadioStateNew() *RadioState {
@@ -55,15 +93,18 @@ func RadioStateNew() *RadioState {
 		tokeiState:     tokeiNormal,
 	}
 
-	// 選局中に一定時間確定しなかったら元の局を表示する
-	v.restoreTimer = time.AfterFunc(stationRestoreDuration, func() {
-		v.pos = v.lastpos
-		infomation.Update(0, v.CurrentStationName())
-	})
+	// 選局中に一定時間確定しなかったら元の局を表示する（main のループで restoreStation を呼ぶ）
+	v.restoreTimer = time.NewTimer(stationRestoreDuration)
 	v.restoreTimer.Stop()
 	return v
 }
 
+// restoreStation 選んだまま確定しなかった局を元に戻す
+func (v *RadioState) restoreStation() {
+	v.pos = v.lastpos
+	infomation.Update(0, v.CurrentStationName())
+}
+
 // GetTokeiState アラームやスリープの設定状況を文字列で返す。
 func (v *RadioState) GetTokeiState() string {
 	var a, s string
@@ -80,9 +121,9 @@ func (v *RadioState) GetTokeiState() string {
 	return a + s
 }
 
-// ChannelUpdate 現在の局を保存する
+// ChannelUpdate 選局した局を現在の局として保存する
 func (v *RadioState) CannelUpdate() {
-	v.lastpos = v.pos
+	v.lastpos = v.tunePos
 }
 
 // IsCannelChange 選局が変更されたかを返す
@@ -95,6 +136,10 @@ func (v *RadioState) GetStateString(c uint8) string {
 	var h, m, flags string
 
 	flags = v.GetTokeiState()
+	if v.IsTrackSkip() {
+		// 曲送り中は再生位置を表示する
+		return v.playbackStateString()
+	}
 	switch v.currState {
 	case stateNormalMode, stateVolumeSet, stateTuneMode:
 		return flags
@@ -115,10 +160,46 @@ func (v *RadioState) GetStateString(c uint8) string {
 		}
 
 		return flags + " " + h + ":" + m
+
+	case stateDirQuery, stateDirBrowse:
+		return v.dirStateString()
+
+	case stateEpisodeSelect, statePlayback:
+		return v.playbackStateString()
+
+	case stateDiagnostics:
+		return v.diagStateString()
+
+	case stateIRLearn:
+		return v.irStateString()
+
+	case stateSettings:
+		return v.menu.String(c == 0)
+
+	case stateHistory:
+		return v.historyStateString()
 	}
 	return ""
 }
 
+// ErrorIndicate 選局のエラーを表示している間は赤 LED を点滅させる
+func (v *RadioState) ErrorIndicate(c uint8) {
+	if infomation.HasError() {
+		v.errorBlink = true
+		if c == 0 {
+			v.RedOn()
+		} else {
+			v.RedOff()
+		}
+		return
+	}
+	if v.errorBlink {
+		// 点滅をやめて状態の色に戻す
+		v.errorBlink = false
+		v.ChangeColor(v.currState)
+	}
+}
+
 // TokeiCheck アラームおよびスリープ時刻をチェックしてそれぞれを起動する
 func (v *RadioState) TokeiCheck() {
 	if v.currState == stateAlarmHourSet ||
@@ -128,21 +209,21 @@ func (v *RadioState) TokeiCheck() {
 	}
 	if (v.tokeiState & tokeiAlarmOn) == tokeiAlarmOn {
 		// アラーム
-		n := time.Now().In(jst)
+		n := timeNow().In(jst)
 		if v.AlarmTime.Hour() == n.Hour() &&
 			v.AlarmTime.Minute() == n.Minute() {
 			v.tokeiState ^= tokeiAlarmOn
 			tune()
-			v.TransitionState(stateVolumeSet)
+			v.Event(eventAlarm)
 		}
 	}
 	if (v.tokeiState & tokeiSleepOn) == tokeiSleepOn {
 		// スリープ
-		n := time.Now().In(jst)
+		n := timeNow().In(jst)
 		if v.TurnOffTime.Hour() == n.Hour() &&
 			v.TurnOffTime.Minute() == n.Minute() {
 			v.tokeiState ^= tokeiSleepOn
-			mpvctl.Stop()
+			mpvStop()
 		}
 	}
 }
@@ -150,11 +231,55 @@ func (v *RadioState) TokeiCheck() {
 // ReadStationListInfo 放送局のリストを設定する
 func (v *RadioState) ReadStationListInfo(s string) error {
 	var err error
-	v.stationList, err = netradio.PrepareStationList(s, 8)
+	v.stationList, v.stationEnc, err = ReadStationList(s, 8)
 	if err != nil {
 		return err
 	}
+	v.stationPath = s
 	v.stationListLen = len(v.stationList)
+	SetStationURLs(v.stationList, config.StationURLs)
+	SortStations(v.stationList, config.StationSort)
+	return nil
+}
+
+// SortStations 局を order の順に並べ替える。選んでいる局、再生中の局はそのままとする。
+func (v *RadioState) SortStations(order string) {
+	if v.stationListLen == 0 {
+		return
+	}
+	pos := v.stationList[v.pos].order
+	lastpos := v.stationList[v.lastpos].order
+	tunePos := v.stationList[v.tunePos].order
+	SortStations(v.stationList, order)
+	for i, s := range v.stationList {
+		if s.order == pos {
+			v.pos = i
+		}
+		if s.order == lastpos {
+			v.lastpos = i
+		}
+		if s.order == tunePos {
+			v.tunePos = i
+		}
+	}
+}
+
+// AddStation 局リストのファイルと選局対象の末尾に局を追加する
+func (v *RadioState) AddStation(group, name, url string) error {
+	entry := StationEntry(group, name, url)
+	if err := AppendStation(v.stationPath, v.stationEnc, entry); err != nil {
+		return err
+	}
+	added := ParseStationList("#EXTM3U\n"+entry, 8)
+	for i := range added {
+		added[i].order = v.stationListLen + i
+	}
+	v.stationList = append(v.stationList, added...)
+	v.stationListLen = len(v.stationList)
+	v.SortStations(config.StationSort)
+	if config.HealthCheckInterval > 0 {
+		stationHealth.SetStations(v.stationList)
+	}
 	return nil
 }
 
@@ -163,9 +288,133 @@ func (v *RadioState) CurrentStationName() string {
 	return v.stationList[v.pos].Name
 }
 
-// CurrentStationURL 現在受信中の局のURLを返す
+// CurrentStationLabel 選局中に表示する局名を返す。応答の無い局には印を付ける。
+func (v *RadioState) CurrentStationLabel() string {
+	if !stationHealth.IsDead(v.stationList[v.pos].order) {
+		return v.CurrentStationName()
+	}
+	r := []rune(deadStationMark + v.CurrentStationName())
+	if len(r) > 8 {
+		r = r[:8]
+	}
+	return string(r)
+}
+
+// isSkipStation 選局時に飛ばす局かどうかを返す
+func (v *RadioState) isSkipStation(n int) bool {
+	return config.SkipDeadStations && stationHealth.IsDead(v.stationList[n].order)
+}
+
+// CurrentStationURL 現在受信中の局のURLを返す。予備のURLに切り替わっていればそれを返す。
 func (v *RadioState) CurrentStationURL() string {
-	return v.stationList[v.pos].Url
+	return v.stationList[v.pos].CurrentUrl()
+}
+
+// TuningStart 選局を始めた事を記録する。選局の結果を待つ間も受信状態とする。
+func (v *RadioState) TuningStart() {
+	v.tuning = true
+	v.radioEnable = true
+}
+
+// TuningEnd 選局が終わった事を記録する
+func (v *RadioState) TuningEnd() {
+	v.tuning = false
+}
+
+// IsTuning 選局中かどうかを返す
+func (v *RadioState) IsTuning() bool {
+	return v.tuning
+}
+
+// TuningIndex 選局中の局の添字を返す
+func (v *RadioState) TuningIndex() int {
+	return v.tunePos
+}
+
+// TuningStationName 選局中（再生中）の局名を返す
+func (v *RadioState) TuningStationName() string {
+	return v.stationList[v.tunePos].Name
+}
+
+// TuningStationURL 選局中（再生中）の局の試しているURLを返す。選局の間に他の局へ
+// 回しても、予備のURLへの切り替えや選局のやり直しはこの局で行う
+func (v *RadioState) TuningStationURL() string {
+	return v.stationList[v.tunePos].CurrentUrl()
+}
+
+// StationLoaded URLを読み込んだ時刻を記録する
+func (v *RadioState) StationLoaded() {
+	v.tuneStart = timeNow()
+	v.audioStarted = false
+}
+
+// AudioStarted 音が出始めた事を記録する
+func (v *RadioState) AudioStarted() {
+	v.audioStarted = true
+	v.reconnecting = false
+}
+
+// IsPlaying 音が出ている（選局中でない）かどうかを返す
+func (v *RadioState) IsPlaying() bool {
+	return v.radioEnable && !v.tuning && v.audioStarted
+}
+
+// SetReconnecting 途切れた配信へ再接続中かどうかを設定する
+func (v *RadioState) SetReconnecting(b bool) {
+	v.reconnecting = b
+}
+
+// IsReconnecting 途切れた配信へ再接続中かどうかを返す
+func (v *RadioState) IsReconnecting() bool {
+	return v.reconnecting
+}
+
+// IsSilent 読み込んでから d 以上経っても音が出ていなければ true を返す
+func (v *RadioState) IsSilent(d time.Duration) bool {
+	return v.radioEnable && !v.tuning && !v.audioStarted && timeNow().Sub(v.tuneStart) >= d
+}
+
+// ResetStationURL 現在の局を選局する局とし、URLの試行回数を初期化して最後に再生できたURLから試す
+func (v *RadioState) ResetStationURL() {
+	v.tunePos = v.pos
+	v.urlTries = 0
+	v.tuneStart = timeNow()
+	st := &v.stationList[v.tunePos]
+	st.trying = st.current
+}
+
+// FirstStationURL 選局中の局の先頭のURLから試し直す
+func (v *RadioState) FirstStationURL() {
+	v.urlTries = 0
+	v.tuneStart = timeNow()
+	v.stationList[v.tunePos].trying = 0
+}
+
+// StationURLWorked 再生中の局の試したURLで音が出たので、次の選局でもそのURLを使う。
+// 使うURLが変わったら true を返す
+func (v *RadioState) StationURLWorked() bool {
+	st := &v.stationList[v.tunePos]
+	if st.current == st.trying {
+		return false
+	}
+	st.current = st.trying
+	return true
+}
+
+// NextStationURL 選局中の局の次の予備URLを試す。全て試し終えていれば false を返す。
+func (v *RadioState) NextStationURL() bool {
+	st := &v.stationList[v.tunePos]
+	n := len(st.Urls())
+	if timeNow().Sub(v.tuneStart) > stationAliveDuration {
+		// しばらく再生できていたなら配信の途絶とみなして数え直す
+		v.urlTries = 0
+	}
+	v.urlTries++
+	if v.urlTries >= n {
+		return false
+	}
+	st.trying = (st.trying + 1) % n
+	return true
 }
 
 // CurrentStationIndex 現在受信中の局の局情報の格納スライスの添字を返す
@@ -188,11 +437,62 @@ func (v *RadioState) IsRadioEnable() bool {
 	return v.radioEnable
 }
 
+// IsBrowsing 局検索あるいは番組の回の選択中かどうかを返す
+func (v *RadioState) IsBrowsing() bool {
+	return v.currState == stateDirQuery || v.currState == stateDirBrowse ||
+		v.currState == stateEpisodeSelect || v.currState == stateDiagnostics ||
+		v.currState == stateIRLearn || v.currState == stateSettings
+}
+
+// SetOnDemand 再生中のものが終わりのある番組か、曲送りのできるものかを設定する
+func (v *RadioState) SetOnDemand(b bool, playlist bool) {
+	v.onDemand = b
+	v.playlist = playlist
+	v.trackSkip = false
+	v.playPos = ""
+	v.trackTitle = ""
+	v.trackArtist = ""
+}
+
+// IsPlaylist 再生中のものが曲送りのできるものかを返す
+func (v *RadioState) IsPlaylist() bool {
+	return v.playlist
+}
+
+// IsTrackSkip 音量調整の中で曲送りをしているかを返す
+func (v *RadioState) IsTrackSkip() bool {
+	return v.currState == stateVolumeSet && v.trackSkip
+}
+
+// SetTrackTag mpv から得た曲の情報を保存し、表示する文字列を返す
+func (v *RadioState) SetTrackTag(name, data string) string {
+	switch name {
+	case "media-title":
+		v.trackTitle = data
+	case "metadata/by-key/artist":
+		v.trackArtist = data
+	}
+	if v.trackArtist == "" {
+		return v.trackTitle
+	}
+	return v.trackArtist + " - " + v.trackTitle
+}
+
+// IsOnDemand 再生中のものが終わりのある番組かを返す
+func (v *RadioState) IsOnDemand() bool {
+	return v.onDemand
+}
+
 // GetState 現在の動作状態を返す
 func (v *RadioState) GetState() StateCode {
 	return v.currState
 }
 
+// SetAlarmTime アラーム時刻を設定する
+func (v *RadioState) SetAlarmTime(h, m int) {
+	v.AlarmTime = time.Date(v.AlarmTime.Year(), v.AlarmTime.Month(), v.AlarmTime.Day(), h, m, 0, 0, time.UTC)
+}
+
 // AlarmTimeInc アラーム時刻を進める
 func (v *RadioState) AlarmTimeInc() {
 	if v.currState == stateAlarmHourSet {
@@ -214,8 +514,11 @@ func (v *RadioState) AlarmTimeDec() {
 // nextTune 選局
 func (v *RadioState) NextTune() {
 	if v.radioEnable {
-		if v.pos < v.stationListLen-1 {
-			v.pos++
+		for n := v.pos + 1; n < v.stationListLen; n++ {
+			if !v.isSkipStation(n) {
+				v.pos = n
+				break
+			}
 		}
 	}
 }
@@ -223,179 +526,144 @@ func (v *RadioState) NextTune() {
 // priorTune 選局
 func (v *RadioState) PriorTune() {
 	if v.radioEnable {
-		if v.pos > 0 {
-			v.pos--
+		for n := v.pos - 1; n >= 0; n-- {
+			if !v.isSkipStation(n) {
+				v.pos = n
+				break
+			}
 		}
 	}
 }
 
-// TransitionState 遷移時に一度だけ実行される動作
-func (v *RadioState) TransitionState(s StateCode) {
-	// 現在のモードの後始末
-	//~ switch v.currState {
-	//~ case stateTuneMode:
-	//~ infomation.Scroll()
-	//~ }
-
-	// 新しく遷移するモードの初期化処理
-	switch s {
-	case stateNormalMode:
-		if v.radioEnable {
-			// ラジオが鳴っていれば入力待ちからボリューム操作へ遷移する
-			s = stateVolumeSet
+// AcceptsDoubleClick 現在の状態でダブルクリックに操作が割り当てられているかどうかを返す。
+// 割り当てが無い状態ではクリックを待たせずに処理する。
+func (v *RadioState) AcceptsDoubleClick() bool {
+	return keymap.Lookup(v.currState, BtnStationReDoubleClick) != ""
+}
+
+// SetMute 消音する・やめる
+func (v *RadioState) SetMute(b bool) {
+	v.muted = b
+	s := "no"
+	if b {
+		s = "yes"
+	}
+	mpvSend("{\"command\": [\"set_property\", \"mute\", \"" + s + "\"]}\x0a")
+}
+
+// IsMuted 消音中かどうかを返す
+func (v *RadioState) IsMuted() bool {
+	return v.muted
+}
+
+// NextGroup 次のグループの最初の局を選ぶ
+func (v *RadioState) NextGroup() {
+	if !v.radioEnable {
+		return
+	}
+	g := v.stationList[v.pos].Group
+	for n := v.pos + 1; n < v.stationListLen; n++ {
+		if v.stationList[n].Group != g && !v.isSkipStation(n) {
+			v.pos = n
+			return
 		}
-	case stateTuneMode:
-		//~ infomation.Fix()
 	}
+}
 
+// PriorGroup 前のグループの最初の局を選ぶ
+func (v *RadioState) PriorGroup() {
+	if !v.radioEnable {
+		return
+	}
+	g := v.stationList[v.pos].Group
+	n := v.pos - 1
+	for n >= 0 && v.stationList[n].Group == g {
+		// 現在のグループより前へ
+		n--
+	}
+	if n < 0 {
+		return
+	}
+	g = v.stationList[n].Group
+	for n > 0 && v.stationList[n-1].Group == g {
+		// そのグループの先頭へ
+		n--
+	}
+	for n < v.pos && v.isSkipStation(n) {
+		n++
+	}
+	v.pos = n
+}
+
+// TransitionState 状態を s へ移す。現在の状態の後始末と s の初期化（stateHooks）を行う。
+// 遷移先は通常 transitions で決めるので、操作や出来事から直接呼ばない
+func (v *RadioState) TransitionState(s StateCode) {
+	if h := stateHooks[v.currState]; h.Exit != nil {
+		h.Exit(v)
+	}
+	if h := stateHooks[s]; h.Enter != nil {
+		h.Enter(v)
+	}
+
+	journal.Write(JournalEntry{Kind: journalState, From: stateName(v.currState), To: stateName(s)})
 	v.currState = s
 	v.ChangeColor(s)
+	v.resetIdle()
 }
 
-// 各モードにおけるボタンへの機能割当
-
-// handleNormalMode ホームポジション
-func (v *RadioState) handleNormalMode(btn ButtonCode) {
-	switch btn {
-	case BtnStationReForward, BtnStationReButton:
-		tune()
-		v.TransitionState(stateVolumeSet)
-	case BtnStationReBackward:
-		// （空きファンクション）
-	case BtnStationReButtonLong:
-		// （空きファンクション）
-	case BtnStationReButtonRepeat:
-		// （空きファンクション）
-	}
-}
-
-// handleAlarmHourSet アラームセット（時）
-func (v *RadioState) handleAlarmHourSet(btn ButtonCode) {
-	switch btn {
-	case BtnStationReForward:
-		v.AlarmTimeInc()
-		lcd.PrintWithPos(0, uint8(1), []byte(v.GetStateString(1)))
-	case BtnStationReBackward:
-		v.AlarmTimeDec()
-		lcd.PrintWithPos(0, uint8(1), []byte(v.GetStateString(1)))
-	case BtnStationReButton:
-		v.TransitionState(stateAlarmMinSet)
-	case BtnStationReButtonLong:
-		v.TransitionState(stateNormalMode)
-	}
-}
-
-// handleAlarmMinSet アラームセット（分）
-func (v *RadioState) handleAlarmMinSet(btn ButtonCode) {
-	switch btn {
-	case BtnStationReForward:
-		v.AlarmTimeInc()
-		lcd.PrintWithPos(0, uint8(1), []byte(v.GetStateString(1)))
-	case BtnStationReBackward:
-		v.AlarmTimeDec()
-		lcd.PrintWithPos(0, uint8(1), []byte(v.GetStateString(1)))
-	case BtnStationReButton:
-		v.TransitionState(stateSelectFunction)
-	case BtnStationReButtonLong:
-		v.TransitionState(stateNormalMode)
-	}
-}
-
-// handleSelectFunction アラームやスリープの設定
-func (v *RadioState) handleSelectFunction(btn ButtonCode) {
-	switch btn {
-	case BtnStationReButton:
-		if v.tokeiState == (tokeiAlarmOn | tokeiSleepOn) {
-			v.tokeiState = 0
-			v.TransitionState(stateAlarmHourSet)
+// Rotate ロータリーエンコーダの1刻みを処理する。速く回した時は状態に応じて複数刻み分進める。
+// ループを継続する場合は false、中断する場合は true を返す
+func (v *RadioState) Rotate(btn ButtonCode, interval time.Duration) bool {
+	s := v.currState
+	n := accelSteps(v.accelCurve(), interval)
+	journal.Write(JournalEntry{Kind: journalRotate, Code: buttonName(btn), Interval: interval})
+	for i := 0; i < n && v.currState == s; i++ {
+		if i > 0 && s == stateVolumeSet && guardVolumeMin.Test(v) {
+			// 音量を下げきったら、速く回していても残りの刻みでラジオを止めない
 			break
 		}
-		v.tokeiState++
-		v.tokeiState &= (tokeiAlarmOn | tokeiSleepOn)
-		if (v.tokeiState & tokeiSleepOn) == tokeiSleepOn {
-			// スリープ時刻の設定を行う
-			v.TurnOffTime = time.Now().Add(30 * time.Minute)
+		if v.dispatch(btn) {
+			return true
 		}
-	case BtnStationReButtonLong:
-		v.TransitionState(stateNormalMode)
 	}
+	return false
 }
 
-// handleTuneMode 選局
-func (v *RadioState) handleTuneMode(btn ButtonCode) {
-	v.restoreTimer.Stop()
-	switch btn {
-	case BtnStationReForward:
-		radioState.NextTune()
-		infomation.Update(0, v.CurrentStationName())
-		v.restoreTimer.Reset(stationRestoreDuration)
-	case BtnStationReBackward:
-		radioState.PriorTune()
-		infomation.Update(0, v.CurrentStationName())
-		v.restoreTimer.Reset(stationRestoreDuration)
-	case BtnStationReButton:
-		tune()
-		v.TransitionState(stateVolumeSet)
-	case BtnStationReButtonLong:
-		v.TransitionState(stateSelectFunction)
-	}
-}
-
-// handleVolumeSet 音量調整
-func (v *RadioState) handleVolumeSet(btn ButtonCode) {
-	switch btn {
-	case BtnStationReForward:
-		if !radioState.IsRadioEnable() {
-			// 右回転でラジオのスイッチを入れる
-			tune()
-		}
-		volume.Increment()
-	case BtnStationReBackward:
-		if volume.Get() == mpvctl.VolumeMin {
-			// 左に回しきった状態ならラジオを止める
-			mpvctl.Stop()
-			v.TransitionState(stateNormalMode)
-		} else {
-			volume.Decrement()
-		}
-	case BtnStationReButton:
-		v.TransitionState(stateTuneMode)
-	case BtnStationReButtonLong:
-		// radio off
-		mpvctl.Stop()
-		v.TransitionState(stateNormalMode)
+// accelCurve 現在の状態で使う加速の設定を返す
+func (v *RadioState) accelCurve() []AccelStep {
+	switch v.currState {
+	case stateTuneMode:
+		return config.Acceleration.Station
+	case stateAlarmMinSet:
+		return config.Acceleration.AlarmMin
+	case stateVolumeSet:
+		return config.Acceleration.Volume
 	}
+	return nil
 }
 
-// handleGlovalEvent モードに関係なく優先的に実行される可能性のある処理
-func (v *RadioState) handleGlovalEvent(btn ButtonCode) bool {
-	if v.currState == stateNormalMode && btn == BtnStationReButtonLong {
-		shutdown()
-		return true
+// accelSteps 刻みの間隔に応じた移動量を返す
+func accelSteps(curve []AccelStep, interval time.Duration) int {
+	for _, c := range curve {
+		if interval < time.Duration(c.Within)*time.Millisecond && c.Steps > 0 {
+			return c.Steps
+		}
 	}
-	return false
+	return 1
 }
 
-// Dispatch 処理の切り替えを行う ループを継続する場合は false、中断する場合は true を返す
+// Dispatch 入力に割り当てられた操作を実行する。割り当ては keymap で状態ごとに決める。
+// ループを継続する場合は false、中断する場合は true を返す
 func (v *RadioState) Dispatch(btn ButtonCode) bool {
-	if v.handleGlovalEvent(btn) {
-		// 優先処理が実行されていれば終わる
-		return true
-	}
+	journal.Write(JournalEntry{Kind: journalButton, Code: buttonName(btn)})
+	return v.dispatch(btn)
+}
 
-	switch v.currState {
-	case stateNormalMode:
-		v.handleNormalMode(btn)
-	case stateVolumeSet:
-		v.handleVolumeSet(btn)
-	case stateTuneMode:
-		v.handleTuneMode(btn)
-	case stateSelectFunction:
-		v.handleSelectFunction(btn)
-	case stateAlarmHourSet:
-		v.handleAlarmHourSet(btn)
-	case stateAlarmMinSet:
-		v.handleAlarmMinSet(btn)
+func (v *RadioState) dispatch(btn ButtonCode) bool {
+	// 選局中に局を確定しないまま戻すタイマーは、操作の度に止める（選局の操作で再び動かす）
+	v.restoreTimer.Stop()
+	if a, ok := trackSkipKeys[btn]; ok && v.IsTrackSkip() {
+		return v.RunAction(a)
 	}
-	return false
+	return v.RunAction(keymap.Lookup(v.currState, btn))
 }
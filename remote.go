@@ -0,0 +1,44 @@
+package main
+
+// RemoteKeymap リモコン（lircd、入力デバイス）のボタンの名前 -> 入力あるいは操作の名前
+type RemoteKeymap map[string]string
+
+// RemoteAction リモコンのボタンに割り当てた操作
+type RemoteAction struct {
+	Action string // 操作の名前
+	Repeat bool   // 押し続けた時の通知
+}
+
+var (
+	// 押し続けた時に繰り返す操作
+	remoteRepeatable = map[string]bool{
+		"volup": true, "voldown": true, "next": true, "prev": true,
+		"nexttrack": true, "prevtrack": true,
+	}
+)
+
+// sendRemote ボタンに割り当てた名前が入力の名前(forward、click 等)ならボタンのコードとして
+// code へ、そうでなければ操作として remote へ送る。押し続けた時は回転だけを繰り返す。
+func sendRemote(a string, repeat bool, code chan<- ButtonCode, remote chan<- RemoteAction) {
+	if b, ok := buttonNames[a]; ok {
+		if repeat && b != BtnStationReForward && b != BtnStationReBackward {
+			return
+		}
+		code <- b
+		return
+	}
+	remote <- RemoteAction{Action: a, Repeat: repeat}
+}
+
+// RunRemote リモコンのボタンに割り当てた操作を実行する。押し続けた時の通知(repeat)では
+// 繰り返しの意味のある操作だけを実行する。ループを継続する場合は false、中断する場合は true を返す
+func (v *RadioState) RunRemote(a string, repeat bool) bool {
+	journal.Write(JournalEntry{Kind: journalAction, Action: a, Repeat: repeat})
+	name, _ := parseAction(a)
+	if repeat && !remoteRepeatable[name] {
+		return false
+	}
+	v.restoreTimer.Stop()
+	lcd.OneShotLight()
+	return v.RunAction(a)
+}
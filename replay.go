@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/sakaisatoru/go_radio_raspi/mpvctl"
+	"github.com/stianeikeland/go-rpio/v4"
+	"io"
+	"local.packages/aqm0802a"
+	"local.packages/volume"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	replayTuneWait time.Duration = time.Second            // 選局の結果を待つ時間
+	replayFollow   time.Duration = 100 * time.Millisecond // 入力に続く遷移とみなす時間
+)
+
+// screenLCD 表示器の代わりに画面の内容と LED の状態を覚えておく
+type screenLCD struct {
+	lines [2][16]byte
+	pins  map[int]rpio.State
+}
+
+func screenLCDNew() *screenLCD {
+	s := &screenLCD{pins: make(map[int]rpio.State)}
+	for i := range s.lines {
+		copy(s.lines[i][:], strings.Repeat(" ", len(s.lines[i])))
+	}
+	return s
+}
+
+func (s *screenLCD) Init()         {}
+func (s *screenLCD) DisplayOff()   {}
+func (s *screenLCD) LightOff()     {}
+func (s *screenLCD) OneShotLight() {}
+
+func (s *screenLCD) SetLightDuration(t time.Duration) {}
+
+func (s *screenLCD) PrintWithPos(x uint8, y uint8, b []byte) {
+	copy(s.lines[y&0x01][x&0x0f:], b)
+}
+
+func (s *screenLCD) UTF8toOLED(m string) ([]byte, int) {
+	return (*aqm0802a.AQM0802A)(nil).UTF8toOLED(m)
+}
+
+func (s *screenLCD) pinWrite(pin int, st rpio.State) {
+	s.pins[pin] = st
+}
+
+// Line 画面の1行（8文字）を UTF-8 で返す。半角カナ以外の記号は ? とする。
+func (s *screenLCD) Line(y int) string {
+	var sb strings.Builder
+	for _, c := range s.lines[y][:8] {
+		switch {
+		case c >= 0x20 && c < 0x7f:
+			sb.WriteByte(c)
+		case c >= 0xa1 && c <= 0xdf:
+			sb.WriteRune(rune(0xff61 + int(c) - 0xa1))
+		default:
+			sb.WriteByte('?')
+		}
+	}
+	return sb.String()
+}
+
+// Led LED の色を返す（端子が Low で点灯）
+func (s *screenLCD) Led() string {
+	g, gok := s.pins[pinReLed1]
+	r, rok := s.pins[pinReLed2]
+	green := gok && g == rpio.Low
+	red := rok && r == rpio.Low
+	switch {
+	case green && red:
+		return "yellow"
+	case green:
+		return "green"
+	case red:
+		return "red"
+	}
+	return "off"
+}
+
+// replayer 記録した入力を RadioState へ送り直す。時刻は記録の時刻で進める。
+type replayer struct {
+	out       io.Writer
+	scr       *screenLCD
+	clock     time.Time
+	restoreAt time.Time // 選局を確定しないまま元の局へ戻す時刻
+	mismatch  int
+}
+
+// replay 記録を読んで、入力毎に状態と画面を out へ書き出す。ハードウェアや mpv は使わない。
+// 記録と異なる状態になった所には !! を付ける。
+func replay(path string, out io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	entries, err := ReadJournal(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("%s: 記録がありません", path)
+	}
+
+	r := &replayer{out: out, scr: screenLCDNew(), clock: entries[0].Time}
+	if err := r.setup(*configPath, *stationsPath); err != nil {
+		return err
+	}
+	for i := 0; i < len(entries); i++ {
+		e := entries[i]
+		r.advance(e.Time)
+		if e.Kind == journalState {
+			// 入力によらない遷移（アラーム、選局の失敗等）
+			fmt.Fprintf(r.out, "%s %-6s %-14s", e.Time.Format("15:04:05.000"), e.Kind, e.From+">"+e.To)
+			r.report(e.To)
+			continue
+		}
+
+		// 入力に続く遷移の記録から、入力後の状態を求める
+		want := ""
+		for i+1 < len(entries) && entries[i+1].Kind == journalState &&
+			entries[i+1].Time.Sub(e.Time) < replayFollow {
+			i++
+			want = entries[i].To
+		}
+		quit := r.input(e)
+		fmt.Fprintf(r.out, "%s %-6s %-14s", e.Time.Format("15:04:05.000"), e.Kind, replayLabel(e))
+		r.report(want)
+		if quit {
+			fmt.Fprintln(r.out, "終了")
+			break
+		}
+	}
+	if r.mismatch > 0 {
+		return fmt.Errorf("%s: 記録と異なる状態が %d 件ありました", path, r.mismatch)
+	}
+	return nil
+}
+
+// setup ハードウェアと mpv の代わりを用意し、設定と局リストを読む
+func (r *replayer) setup(configPath, stationsPath string) error {
+	lcd = r.scr
+	pinWrite = r.scr.pinWrite
+	mpvSend = func(string) error { return nil }
+	tuneResolve = func(ctx context.Context, u string) (Resolved, error) {
+		return Resolved{URL: u}, nil
+	}
+	timeNow = func() time.Time { return r.clock }
+	configUpdate = func(string, *Config, ...string) error { return nil }
+
+	mpvctl.SetVoltable(&voltable)
+	volume.Set(mpvctl.VolumeMax / 3)
+
+	var err error
+	infomation = InfomationDisplayNew()
+	radioState = RadioStateNew()
+	config, err = LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	applyConfig()
+	stationHealth = HealthCheckerNew(time.Minute, time.Second)
+	if err := radioState.ReadStationListInfo(stationsPath); err != nil {
+		return err
+	}
+	registerActions()
+	keymap, err = KeymapNew(config.Keymap)
+	radioState.GreenOn()
+	return err
+}
+
+// advance t まで時刻を進める。その間のアラームやスリープ、選局の取り消しを起こす。
+func (r *replayer) advance(t time.Time) {
+	for next := r.clock.Truncate(time.Minute).Add(time.Minute); !next.After(t); next = next.Add(time.Minute) {
+		r.tick(next)
+		radioState.TokeiCheck()
+		r.settle()
+	}
+	r.tick(t)
+}
+
+// tick 時刻を t にする。選局を確定しないまま時間が経っていれば元の局に戻し、
+// 入力の無いまま時間が経っていれば入力待ちへ戻す。
+func (r *replayer) tick(t time.Time) {
+	if !r.restoreAt.IsZero() && !r.restoreAt.After(t) {
+		r.clock = r.restoreAt
+		r.restoreAt = time.Time{}
+		radioState.restoreStation()
+	}
+	if u := radioState.idleUntil; !u.IsZero() && !u.After(t) {
+		r.clock = u
+		radioState.IdleCheck()
+		r.settle()
+	}
+	r.clock = t
+}
+
+// input 記録した入力を送る。ループを中断する操作であれば true を返す
+func (r *replayer) input(e JournalEntry) bool {
+	var quit bool
+	// 入力の度に選局の取り消しのタイマーは止まる。動かし直せば settle で置き換える
+	r.restoreAt = time.Time{}
+	switch e.Kind {
+	case journalButton:
+		quit = radioState.Dispatch(buttonNames[e.Code])
+	case journalRotate:
+		quit = radioState.Rotate(buttonNames[e.Code], e.Interval)
+	case journalAction:
+		quit = radioState.RunRemote(e.Action, e.Repeat)
+	}
+	r.settle()
+	return quit
+}
+
+// settle 選局や問い合わせの結果を受け取り、選局の取り消しのタイマーを模擬時刻に置き換える
+func (r *replayer) settle() {
+	for radioState.IsTuning() || fetchPending {
+		select {
+		case res := <-tuneDone:
+			tuneCompleted(res)
+		case res := <-fetchDone:
+			fetchCompleted(res)
+		case <-time.After(replayTuneWait):
+			return
+		}
+	}
+	if radioState.restoreTimer.Stop() {
+		r.restoreAt = r.clock.Add(stationRestoreDuration)
+	}
+}
+
+// report 状態と画面を書き出す。want が空でなく、状態が異なれば印を付ける
+func (r *replayer) report(want string) {
+	colon ^= 1
+	infomation.ShowClock(radioState.GetStateString(colon))
+	got := stateName(radioState.GetState())
+	fmt.Fprintf(r.out, " %-11s |%s|%s| %s", got, r.scr.Line(0), r.scr.Line(1), r.scr.Led())
+	if want != "" && want != got {
+		r.mismatch++
+		fmt.Fprintf(r.out, " !! 記録では %s", want)
+	}
+	fmt.Fprintln(r.out)
+}
+
+// replayLabel 入力の表示
+func replayLabel(e JournalEntry) string {
+	switch e.Kind {
+	case journalRotate:
+		return fmt.Sprintf("%s %dms", e.Code, e.Interval.Milliseconds())
+	case journalAction:
+		if e.Repeat {
+			return e.Action + " (rep)"
+		}
+		return e.Action
+	}
+	return e.Code
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	pluginScheme string = "plugin:"
+)
+
+// Resolved 再生に使うURLと、その再生に限って mpv へ渡すオプション
+type Resolved struct {
+	URL      string
+	Options  map[string]string
+	OnDemand bool // 終わりのある番組（シーク可能）
+	Playlist bool // 複数の曲からなる（曲送り可能）
+}
+
+// Resolver plugin:/<名前>/<引数> あるいは <名前>:<引数> 形式の局を
+// 実際に再生するURLへ変換する。後者の場合は Name() が "file:" の様に ":" で終わる。
+type Resolver interface {
+	Name() string
+	Resolve(ctx context.Context, arg string) (Resolved, error)
+}
+
+// ProxyResolver 代理サーバーを介して再生させる Resolver
+type ProxyResolver interface {
+	Resolver
+	Start()
+	Stop()
+}
+
+// CheapResolver 死活確認でURLを求めてよい（問い合わせが軽い）Resolver
+type CheapResolver interface {
+	Resolver
+	IsCheap() bool
+}
+
+// EpisodeResolver 配信されている回の一覧から選んで再生するオンデマンドの Resolver
+type EpisodeResolver interface {
+	Resolver
+	Episodes(ctx context.Context, arg string) ([]Episode, error)
+	Select(arg string, guid string)
+}
+
+// RetryPolicy URLを求める際の再挑戦の方針
+type RetryPolicy struct {
+	Attempts int           // 試行回数（1以上）
+	Wait     time.Duration // 再挑戦までの待ち時間
+}
+
+type resolverEntry struct {
+	Resolver
+	retry RetryPolicy
+}
+
+var (
+	resolvers = make(map[string]resolverEntry)
+)
+
+// RegisterResolver Resolver を登録する。同じ名前で登録すると置き換える。
+func RegisterResolver(r Resolver, p RetryPolicy) {
+	if p.Attempts < 1 {
+		p.Attempts = 1
+	}
+	resolvers[r.Name()] = resolverEntry{Resolver: r, retry: p}
+}
+
+// ResolverNames 登録されている Resolver の名前を返す
+func ResolverNames() []string {
+	names := make([]string, 0, len(resolvers))
+	for k := range resolvers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParsePluginURL plugin:/<名前>/<引数> を分解する。引数には "/" を含んでよい。
+func ParsePluginURL(u string) (string, string, bool) {
+	args := strings.SplitN(u, "/", 3)
+	if args[0] != pluginScheme || len(args) < 3 {
+		return "", "", false
+	}
+	return args[1], args[2], true
+}
+
+// lookupResolver URLに対応する Resolver を返す。対応するものが無いURLには nil を返す。
+func lookupResolver(u string) (*resolverEntry, string, error) {
+	if scheme, arg, ok := strings.Cut(u, ":"); ok {
+		if e, ok := resolvers[scheme+":"]; ok {
+			return &e, arg, nil
+		}
+	}
+	if !strings.HasPrefix(u, pluginScheme) {
+		return nil, "", nil
+	}
+	name, arg, ok := ParsePluginURL(u)
+	if !ok {
+		return nil, "", fmt.Errorf("%s: 不正な plugin の指定", u)
+	}
+	e, ok := resolvers[name]
+	if !ok {
+		return nil, "", fmt.Errorf("%s: 登録されていない plugin", name)
+	}
+	return &e, arg, nil
+}
+
+// LookupEpisodeResolver URLが回を選んで再生する局であれば、その Resolver と引数を返す
+func LookupEpisodeResolver(u string) (EpisodeResolver, string, bool) {
+	e, arg, err := lookupResolver(u)
+	if err != nil || e == nil {
+		return nil, "", false
+	}
+	er, ok := e.Resolver.(EpisodeResolver)
+	return er, arg, ok
+}
+
+// ResolveStationURL 局リストのURLを再生するURLへ変換する。plugin: 以外はそのまま返す。
+// 代理サーバーを使うものは起動し、今回使わない代理サーバーは止める。
+func ResolveStationURL(ctx context.Context, u string) (Resolved, error) {
+	var (
+		rv  Resolved
+		err error
+	)
+
+	e, arg, err := lookupResolver(u)
+	if err != nil {
+		return rv, err
+	}
+	stopProxies(e)
+	if e == nil {
+		return Resolved{URL: u}, nil
+	}
+
+	for i := 0; i < e.retry.Attempts; i++ {
+		if i > 0 {
+			log.Printf("%s: %v 再挑戦します", e.Name(), err)
+			select {
+			case <-ctx.Done():
+				return rv, ctx.Err()
+			case <-time.After(e.retry.Wait):
+			}
+		}
+		rv, err = resolveWithContext(ctx, e, arg)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return rv, err
+	}
+
+	if p, ok := e.Resolver.(ProxyResolver); ok {
+		p.Start()
+	}
+	return rv, nil
+}
+
+// resolveWithContext Resolve を呼び出す。ctx を見ない Resolver であっても
+// 取り消しや制限時間で戻れるよう、別の goroutine で実行して待つ。取り消した後も Resolve は
+// 終わるまで動き続けるので、共有するものを書き換える Resolver は自身で ctx を確かめて順に行う。
+func resolveWithContext(ctx context.Context, r Resolver, arg string) (Resolved, error) {
+	type result struct {
+		rv  Resolved
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		rv, err := r.Resolve(ctx, arg)
+		ch <- result{rv, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return Resolved{}, ctx.Err()
+	case res := <-ch:
+		return res.rv, res.err
+	}
+}
+
+// ResolveForProbe 死活確認用にURLを求める。問い合わせの重い plugin では ok が false となる。
+func ResolveForProbe(ctx context.Context, u string) (string, bool, error) {
+	e, arg, err := lookupResolver(u)
+	if err != nil {
+		return "", false, err
+	}
+	if e == nil {
+		return u, true, nil
+	}
+	if c, ok := e.Resolver.(CheapResolver); !ok || !c.IsCheap() {
+		return "", false, nil
+	}
+	rv, err := resolveWithContext(ctx, e, arg)
+	return rv.URL, true, err
+}
+
+// stopProxies 使わない代理サーバーを止める
+func stopProxies(current *resolverEntry) {
+	for _, e := range resolvers {
+		if current != nil && e.Name() == current.Name() {
+			continue
+		}
+		if p, ok := e.Resolver.(ProxyResolver); ok {
+			p.Stop()
+		}
+	}
+}
+
+// loadResolved 求めたURLを mpv に読み込ませる
+func loadResolved(r Resolved) error {
+	if len(r.Options) == 0 {
+		return mpvLoadfile(r.URL)
+	}
+
+	opts := make([]string, 0, len(r.Options))
+	for k, v := range r.Options {
+		opts = append(opts, k+"="+v)
+	}
+	sort.Strings(opts)
+	b, err := json.Marshal(map[string]any{
+		"command": map[string]string{
+			"name":    "loadfile",
+			"url":     r.URL,
+			"flags":   "replace",
+			"options": strings.Join(opts, ","),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return mpvSend(string(b) + "\x0a")
+}
@@ -13,6 +13,17 @@ const (
 	Backward
 )
 
+// Step 1刻み分の回転。Interval は同じ向きに回した前の刻みからの間隔で、
+// 向きが変わった時や最初の刻みでは IntervalIdle となる。
+type Step struct {
+	Dir      REvector
+	Interval time.Duration
+}
+
+const (
+	IntervalIdle time.Duration = time.Second
+)
+
 type RotaryEncoder struct {
 	pinA         rpio.Pin
 	pinB         rpio.Pin
@@ -20,6 +31,12 @@ type RotaryEncoder struct {
 	samplingtime int
 	cbForward    func()
 	cbBackward   func()
+
+	// 相の変化の解読
+	idx      uint8
+	store    int
+	lastDir  REvector
+	lastTime time.Time
 }
 
 var (
@@ -70,30 +87,66 @@ func (r *RotaryEncoder) SetSamplingTime(n int) int {
 
 // DetectLoop デテント型エンコーダ専用（1刻みで4相動く）
 func (r *RotaryEncoder) DetectLoop(code chan<- REvector) {
-	var (
-		idx, current uint8
-		store        int
-	)
+	r.poll(func(s Step) {
+		code <- s.Dir
+	})
+}
+
+// DetectStepLoop DetectLoop と同じだが、刻みの間隔も送る（回す速さに応じた加速用）
+func (r *RotaryEncoder) DetectStepLoop(code chan<- Step) {
+	r.poll(func(s Step) {
+		code <- s
+	})
+}
+
+// FeedLoop 端子を読む代わりに、変化の都度送られてくる A<<1|B の値を解読する（エッジ検出用）
+func (r *RotaryEncoder) FeedLoop(levels <-chan uint8, code chan<- Step) {
+	for current := range levels {
+		if s, ok := r.Feed(current); ok {
+			code <- s
+		}
+	}
+}
+
+func (r *RotaryEncoder) poll(emit func(Step)) {
 	for {
 		time.Sleep(time.Duration(r.samplingtime) * time.Millisecond)
-		current = uint8(r.pinA.Read())<<1 | uint8(r.pinB.Read())
-		idx = (idx << 2) | current
-		store += dir[idx&15]
-		if current == 3 {
-			switch {
-			case store <= -4:
-				store = 0
-				r.counter++
-				r.cbForward()
-				code <- Forward
-			case store >= 4:
-				store = 0
-				r.counter--
-				r.cbBackward()
-				code <- Backward
-			default:
-				store = 0 // チャタリングで数値が暴れたら消去
-			}
+		if s, ok := r.Feed(uint8(r.pinA.Read())<<1 | uint8(r.pinB.Read())); ok {
+			emit(s)
 		}
 	}
 }
+
+// Feed 端子の値 A<<1|B を1つ解読する。1刻み分回っていれば ok が true となる。
+func (r *RotaryEncoder) Feed(current uint8) (Step, bool) {
+	r.idx = (r.idx << 2) | current
+	r.store += dir[r.idx&15]
+	if current != 3 {
+		return Step{}, false
+	}
+	switch {
+	case r.store <= -4:
+		r.store = 0
+		r.counter++
+		r.cbForward()
+		return r.step(Forward), true
+	case r.store >= 4:
+		r.store = 0
+		r.counter--
+		r.cbBackward()
+		return r.step(Backward), true
+	}
+	r.store = 0 // チャタリングで数値が暴れたら消去
+	return Step{}, false
+}
+
+func (r *RotaryEncoder) step(d REvector) Step {
+	now := time.Now()
+	interval := now.Sub(r.lastTime)
+	if d != r.lastDir || interval > IntervalIdle {
+		interval = IntervalIdle
+	}
+	r.lastDir = d
+	r.lastTime = now
+	return Step{Dir: d, Interval: interval}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"local.packages/volume"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	scenarioRotateInterval time.Duration = 300 * time.Millisecond // rotate で間隔を省いた時の値
+)
+
+// scenario 入力と時刻の進み、画面や LED、mpv へ送った命令の期待値を書いた台本を
+// 記録の再生と同じ偽物の上で実行する。1行1命令で # から後は読まない。
+//
+//	stations stations.m3u        局リスト（台本からの相対パス、最初の入力より前に書く）
+//	config radio.json            設定ファイル（同上、省けば既定値）
+//	at 2026-07-06 06:29:59       時刻を設定する（以降は進める方向のみ）
+//	alarm 06:30                  アラームをその時刻に設定して入れる
+//	press click                  ボタンの入力（keymap の入力の名前）
+//	rotate forward 30ms          ロータリーエンコーダの1刻み（間隔は省略できる）
+//	action volup                 操作（リモコンのボタンと同じ）
+//	wait 1s                      時刻を進める（アラーム、無操作、選局の取り消しが起きる）
+//	expect state volume          状態
+//	expect line0 ｽﾏｲﾙﾗｼﾞｵ        画面の1行目（前後の空白は比べない。前の空白も比べるなら "" で囲む）
+//	expect line1 "   06:30"      画面の2行目（時計の : は点灯した状態で比べる）
+//	expect led green             LED の色 green red yellow off
+//	expect volume 3              音量
+//	expect mpv loadfile          前の expect mpv から後に mpv へ送った命令に含まれる文字列
+//	expect nompv                 前の expect mpv から後に mpv へ命令を送っていない
+type scenario struct {
+	r       *replayer
+	dir     string
+	config  string
+	station string
+	ready   bool
+	clocked bool     // at で時刻を設定した
+	sent    []string // mpv へ送った命令
+	expects int
+	out     io.Writer
+}
+
+// runScenarios 台本を順に実行する。期待と異なるものがあれば out に書いてエラーを返す。
+// 台本は期待と異なった所で打ち切る。
+func runScenarios(pattern string, out io.Writer) error {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("%s: 台本がありません", pattern)
+	}
+	failed := 0
+	for _, p := range paths {
+		if err := runScenario(p, out); err != nil {
+			fmt.Fprintln(out, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d/%d の台本が期待と異なりました", failed, len(paths))
+	}
+	return nil
+}
+
+// runScenario 台本を1つ実行する
+func runScenario(path string, out io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := &scenario{
+		dir:     filepath.Dir(path),
+		config:  filepath.Join(filepath.Dir(path), "radio.json"),
+		station: filepath.Join(filepath.Dir(path), "stations.m3u"),
+		out:     out,
+	}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+		if err := s.exec(args, strings.TrimSpace(line)); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "ok %s (%d)\n", path, s.expects)
+	return nil
+}
+
+// start 最初の入力の前に偽物を用意する
+func (s *scenario) start() error {
+	if s.ready {
+		return nil
+	}
+	s.r = &replayer{out: s.out, scr: screenLCDNew(), clock: time.Date(2026, time.July, 6, 12, 0, 0, 0, jst)}
+	if err := s.r.setup(s.config, s.station); err != nil {
+		return err
+	}
+	mpvSend = func(c string) error {
+		s.sent = append(s.sent, c)
+		return nil
+	}
+	s.ready = true
+	return nil
+}
+
+func (s *scenario) exec(args []string, line string) error {
+	switch args[0] {
+	case "stations", "config":
+		if s.ready || len(args) != 2 {
+			return fmt.Errorf("%s は最初の入力より前に1つだけ書きます", args[0])
+		}
+		p := filepath.Join(s.dir, args[1])
+		if args[0] == "config" {
+			s.config = p
+		} else {
+			s.station = p
+		}
+		return nil
+	}
+
+	if err := s.start(); err != nil {
+		return err
+	}
+	switch args[0] {
+	case "at":
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", strings.Join(args[1:], " "), jst)
+		if err != nil {
+			return err
+		}
+		if !s.clocked {
+			// 最初の at は時刻を合わせるだけとする
+			s.r.clock = t
+			s.clocked = true
+			break
+		}
+		if t.Before(s.r.clock) {
+			return fmt.Errorf("at %s: 時刻は戻せません", t.Format(time.DateTime))
+		}
+		s.r.advance(t)
+	case "wait":
+		if len(args) != 2 {
+			return fmt.Errorf("wait の後に時間を書きます")
+		}
+		d, err := time.ParseDuration(args[1])
+		if err != nil {
+			return err
+		}
+		s.r.advance(s.r.clock.Add(d))
+	case "alarm":
+		var h, m int
+		if len(args) != 2 {
+			return fmt.Errorf("alarm の後に時刻を書きます")
+		}
+		if _, err := fmt.Sscanf(args[1], "%d:%d", &h, &m); err != nil {
+			return err
+		}
+		radioState.SetAlarmTime(h, m)
+		radioState.tokeiState |= tokeiAlarmOn
+	case "press":
+		if len(args) != 2 {
+			return fmt.Errorf("press の後に入力を書きます")
+		}
+		if _, ok := buttonNames[args[1]]; !ok {
+			return fmt.Errorf("%s: 不明な入力", args[1])
+		}
+		s.r.input(JournalEntry{Kind: journalButton, Code: args[1]})
+	case "rotate":
+		if len(args) < 2 || len(args) > 3 {
+			return fmt.Errorf("rotate forward|backward [間隔]")
+		}
+		if _, ok := buttonNames[args[1]]; !ok {
+			return fmt.Errorf("%s: 不明な入力", args[1])
+		}
+		d := scenarioRotateInterval
+		if len(args) == 3 {
+			var err error
+			if d, err = time.ParseDuration(args[2]); err != nil {
+				return err
+			}
+		}
+		s.r.input(JournalEntry{Kind: journalRotate, Code: args[1], Interval: d})
+	case "action":
+		if len(args) < 2 {
+			return fmt.Errorf("action の後に操作を書きます")
+		}
+		s.r.input(JournalEntry{Kind: journalAction, Action: strings.Join(args[1:], " ")})
+	case "expect":
+		if len(args) < 2 {
+			return fmt.Errorf("expect の後に比べるものを書きます")
+		}
+		want := strings.TrimSpace(strings.TrimPrefix(line, "expect"))
+		return s.expect(args[1], strings.TrimSpace(strings.TrimPrefix(want, args[1])))
+	default:
+		return fmt.Errorf("%s: 不明な命令", args[0])
+	}
+	return nil
+}
+
+// render 時計の : を点灯させた状態で画面を描き直す
+func (s *scenario) render() {
+	colon = 1
+	infomation.ShowClock(radioState.GetStateString(colon))
+}
+
+func (s *scenario) expect(what, want string) error {
+	var got string
+	s.expects++
+	switch what {
+	case "state":
+		got = stateName(radioState.GetState())
+	case "line0", "line1":
+		s.render()
+		got = s.r.scr.Line(int(what[4] - '0'))
+		if strings.HasPrefix(want, "\"") {
+			w, err := strconv.Unquote(want)
+			if err != nil {
+				return err
+			}
+			want = strings.TrimRight(w, " ")
+			got = strings.TrimRight(got, " ")
+		} else {
+			got = strings.TrimSpace(got)
+		}
+	case "led":
+		s.render()
+		got = s.r.scr.Led()
+	case "volume":
+		got = strconv.Itoa(int(volume.Get()))
+	case "mpv":
+		sent := s.sent
+		s.sent = nil
+		for _, c := range sent {
+			if strings.Contains(c, want) {
+				return nil
+			}
+		}
+		got = strings.TrimSpace(strings.Join(sent, " "))
+	case "nompv":
+		got = strings.TrimSpace(strings.Join(s.sent, " "))
+		s.sent = nil
+	default:
+		return fmt.Errorf("expect %s: 比べられません", what)
+	}
+	if got != want {
+		return fmt.Errorf("expect %s %q: %q", what, want, got)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+// testdata/scenario の台本を1つずつ実行する。go test -race でループの外から状態を
+// 変えていないことも確かめる
+func TestScenarios(t *testing.T) {
+	paths, err := filepath.Glob("testdata/scenario/*.txt")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no scenarios: %v", err)
+	}
+	for _, p := range paths {
+		t.Run(filepath.Base(p), func(t *testing.T) {
+			var out bytes.Buffer
+			if err := runScenario(p, &out); err != nil {
+				t.Errorf("%v\n%s", err, out.String())
+			}
+		})
+	}
+}
+
+// testdata/journal の記録を再生し、記録と同じ状態を辿ることを確かめる
+func TestReplayJournals(t *testing.T) {
+	conf, stations := *configPath, *stationsPath
+	t.Cleanup(func() { *configPath, *stationsPath = conf, stations })
+	*configPath = filepath.Join(t.TempDir(), "radio.json")
+	*stationsPath = "radio.m3u"
+
+	paths, err := filepath.Glob("testdata/journal/*.jsonl")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no journals: %v", err)
+	}
+	for _, p := range paths {
+		t.Run(filepath.Base(p), func(t *testing.T) {
+			var out bytes.Buffer
+			if err := replay(p, &out); err != nil {
+				t.Errorf("%v\n%s", err, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sakaisatoru/go_radio_raspi/mpvctl"
+	"local.packages/volume"
+	"log"
+	"time"
+	_ "time/tzdata" // zoneinfo の無いイメージでもタイムゾーンを選べるよう埋め込む
+)
+
+var (
+	// 設定ファイルへ書き込む。記録の再生では書き込まない
+	configUpdate = UpdateConfigFile
+
+	// 選べるタイムゾーン。Label は2行目に表示する
+	settingsTimezones = []EnumOption{
+		{"Tokyo", "Asia/Tokyo"},
+		{"Seoul", "Asia/Seoul"},
+		{"Shanghai", "Asia/Shanghai"},
+		{"Sydney", "Australia/Sydney"},
+		{"UTC", "UTC"},
+		{"London", "Europe/London"},
+		{"Berlin", "Europe/Berlin"},
+		{"NewYork", "America/New_York"},
+		{"Chicago", "America/Chicago"},
+		{"LA", "America/Los_Angeles"},
+	}
+	settingsStationSorts = []EnumOption{
+		{"file", stationSortFile},
+		{"name", stationSortName},
+		{"group", stationSortGroup},
+	}
+)
+
+// settingsMenu 設定メニューの項目。値は config を読み書きし、変えた値はすぐに反映する。
+func settingsMenu(v *RadioState) []*MenuItem {
+	return []*MenuItem{
+		{Label: "ﾄｹｲ", Items: []*MenuItem{
+			{Label: "ｱﾗｰﾑ", Key: "alarm_time", Editor: &TimeEditor{
+				Get: func() (int, int) {
+					return v.AlarmTime.Hour(), v.AlarmTime.Minute()
+				},
+				Set: func(h, m int) {
+					config.AlarmTime = fmt.Sprintf("%02d:%02d", h, m)
+					v.SetAlarmTime(h, m)
+				},
+			}},
+			{Label: "ｽﾘｰﾌﾟ", Key: "sleep_duration", Editor: &NumberEditor{
+				Get:  func() int { return config.SleepDuration },
+				Set:  func(n int) { config.SleepDuration = n },
+				Min:  5,
+				Max:  180,
+				Inc:  5,
+				Unit: "min",
+			}},
+			{Label: "ﾀｲﾑｿﾞｰﾝ", Key: "timezone", Editor: &EnumEditor{
+				Get: func() string { return config.Timezone },
+				Set: func(s string) {
+					config.Timezone = s
+					applyTimezone()
+				},
+				Options: settingsTimezones,
+			}},
+		}},
+		{Label: "ｵﾝﾘｮｳ", Items: []*MenuItem{
+			{Label: "ｼﾞｮｳｹﾞﾝ", Key: "volume_limit", Editor: &NumberEditor{
+				Get: func() int { return config.VolumeLimit },
+				Set: func(n int) {
+					config.VolumeLimit = n
+					applyVolumeLimit()
+				},
+				Min:  0,
+				Max:  int(mpvctl.VolumeMax),
+				Inc:  1,
+				Zero: "none",
+			}},
+			{Label: "ｼｰｸ", Key: "seek_step", Editor: &NumberEditor{
+				Get:  func() int { return config.SeekStep },
+				Set:  func(n int) { config.SeekStep = n },
+				Min:  5,
+				Max:  300,
+				Inc:  5,
+				Unit: "s",
+			}},
+		}},
+		{Label: "ｷｮｸ", Items: []*MenuItem{
+			{Label: "ﾅﾗﾋﾞ", Key: "station_sort", Editor: &EnumEditor{
+				Get: func() string { return config.StationSort },
+				Set: func(s string) {
+					config.StationSort = s
+					v.SortStations(s)
+				},
+				Options: settingsStationSorts,
+			}},
+			{Label: "ﾑｵｳﾄｳｽｷｯﾌﾟ", Key: "skip_dead_stations", Editor: &BoolEditor{
+				Get: func() bool { return config.SkipDeadStations },
+				Set: func(b bool) { config.SkipDeadStations = b },
+			}},
+		}},
+		{Label: "ﾋｮｳｼﾞ", Items: []*MenuItem{
+			{Label: "ﾗｲﾄ", Key: "backlight_duration", Editor: &NumberEditor{
+				Get: func() int { return config.BacklightDuration },
+				Set: func(n int) {
+					config.BacklightDuration = n
+					applyBacklight()
+				},
+				Min:  5,
+				Max:  300,
+				Inc:  5,
+				Unit: "s",
+			}},
+			{Label: "ﾀﾞﾌﾞﾙｸﾘｯｸ", Key: "double_click_window", Editor: &NumberEditor{
+				Get:  func() int { return config.DoubleClickWindow },
+				Set:  func(n int) { config.DoubleClickWindow = n },
+				Min:  0,
+				Max:  800,
+				Inc:  50,
+				Unit: "ms",
+				Zero: "off",
+			}},
+		}},
+	}
+}
+
+// applyConfig 設定ファイルの値のうち、読み込んだ後に反映が必要なものを反映する
+func applyConfig() {
+	applyBacklight()
+	applyVolumeLimit()
+	applyTimezone()
+	var err error
+	if idleDurations, err = IdleDurationsNew(config.IdleTimeouts); err != nil {
+		log.Println(err)
+	}
+	var h, m int
+	if _, err := fmt.Sscanf(config.AlarmTime, "%d:%d", &h, &m); err != nil {
+		log.Printf("alarm_time %s: %v", config.AlarmTime, err)
+	} else {
+		radioState.SetAlarmTime(h, m)
+	}
+}
+
+func applyBacklight() {
+	lcd.SetLightDuration(time.Duration(config.BacklightDuration) * time.Second)
+}
+
+func applyVolumeLimit() {
+	if config.VolumeLimit <= 0 {
+		volume.SetLimit(-1)
+		return
+	}
+	volume.SetLimit(int8(min(config.VolumeLimit, int(mpvctl.VolumeMax))))
+}
+
+// applyTimezone 時計とアラームのタイムゾーンを設定する。分からなければ今のままとする
+func applyTimezone() {
+	loc, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	jst = loc
+}
+
+// saveSettings 設定メニューで変えた項目を設定ファイルに書き込む
+func saveSettings(keys ...string) {
+	if err := configUpdate(*configPath, config, keys...); err != nil {
+		log.Println(err)
+		infomation.ShowError(ErrorHup)
+	}
+}
+
+// saveStationURLs 局ごとに最後に再生できたURLを設定ファイルに書き込む
+func saveStationURLs() {
+	m := make(map[string]int)
+	for _, s := range radioState.stationList {
+		if s.current > 0 {
+			m[s.Url] = s.current
+		}
+	}
+	config.StationURLs = m
+	saveSettings("station_urls")
+}
+
+// saveAlarmTime アラーム時刻の設定を終えたら設定ファイルに書き込む
+func saveAlarmTime(v *RadioState) {
+	s := v.AlarmTime.Format("15:04")
+	if s == config.AlarmTime {
+		return
+	}
+	config.AlarmTime = s
+	saveSettings("alarm_time")
+}
+
+// showMenu 設定メニューの項目名と値を表示する
+func (v *RadioState) showMenu() {
+	infomation.Update(0, v.menu.Label())
+	lcd.PrintWithPos(0, uint8(1), []byte(v.GetStateString(1)))
+}
+
+// actSelect 設定メニューの項目を選ぶ。値の編集を終えたら設定ファイルに書き込む
+func (v *RadioState) actSelect(arg string) {
+	if it := v.menu.Select(); it != nil && it.Key != "" {
+		saveSettings(it.Key)
+	}
+	v.showMenu()
+}
+
+// actBack 設定メニューの編集をやめるか、上の階層へ戻る。最上位では設定メニューを終える
+func (v *RadioState) actBack(arg string) {
+	if v.menu.IsTop() {
+		v.actHome(arg)
+		return
+	}
+	v.menu.Back()
+	v.showMenu()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	stallCacheEmpty float64 = 0.5 // これより先読みが少なければ途切れているとみなす（秒）
+)
+
+var (
+	errStalled       = errors.New("stream stalled")
+	errUnexpectedEOF = errors.New("unexpected end of stream")
+
+	streamStall StallDetector
+)
+
+// StallDetector mpv の core-idle、paused-for-cache、eof-reached、demuxer-cache-duration を
+// 見て、再生中の配信が途切れたかどうかを判断する
+type StallDetector struct {
+	coreIdle       bool
+	pausedForCache bool
+	eof            bool
+	cacheDuration  float64
+	idleSince      time.Time // 再生が止まった時刻
+}
+
+// Reset URLを読み込み直した時に状態を初期化する
+func (d *StallDetector) Reset() {
+	*d = StallDetector{}
+}
+
+// Update mpv のプロパティの変化を受け取る
+func (d *StallDetector) Update(name, data string, now time.Time) {
+	stalled := d.isStalled()
+	switch name {
+	case "core-idle":
+		d.coreIdle = data == "yes"
+	case "paused-for-cache":
+		d.pausedForCache = data == "yes"
+	case "eof-reached":
+		d.eof = data == "yes"
+	case "demuxer-cache-duration":
+		d.cacheDuration, _ = strconv.ParseFloat(data, 64)
+	}
+	if !stalled && d.isStalled() {
+		d.idleSince = now
+	}
+}
+
+func (d *StallDetector) isStalled() bool {
+	return d.pausedForCache || (d.coreIdle && d.cacheDuration < stallCacheEmpty)
+}
+
+// Check 配信が終わっているか、limit 以上止まったままであればエラーを返す
+func (d *StallDetector) Check(now time.Time, limit time.Duration) error {
+	if d.eof {
+		return errUnexpectedEOF
+	}
+	if d.isStalled() && now.Sub(d.idleSince) >= limit {
+		return errStalled
+	}
+	return nil
+}
+
+// IsStallProperty 途切れの判断に使うプロパティかどうかを返す
+func IsStallProperty(name string) bool {
+	switch name {
+	case "core-idle", "paused-for-cache", "eof-reached", "demuxer-cache-duration":
+		return true
+	}
+	return false
+}
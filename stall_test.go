@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStallDetector(t *testing.T) {
+	type update struct {
+		after      time.Duration // 開始からの時間
+		name, data string
+	}
+	const limit = 10 * time.Second
+	start := time.Date(2026, time.July, 6, 12, 0, 0, 0, jst)
+
+	for _, c := range []struct {
+		name    string
+		updates []update
+		check   time.Duration // 開始から Check するまでの時間
+		want    error
+	}{
+		{"healthy", []update{
+			{0, "core-idle", "no"},
+			{0, "demuxer-cache-duration", "4.5"},
+		}, time.Minute, nil},
+		{"idle with cache", []update{
+			{0, "core-idle", "yes"},
+			{0, "demuxer-cache-duration", "3.0"},
+		}, time.Minute, nil},
+		{"stalled", []update{
+			{0, "demuxer-cache-duration", "0.1"},
+			{2 * time.Second, "core-idle", "yes"},
+		}, 2*time.Second + limit, errStalled},
+		{"not stalled long enough", []update{
+			{0, "demuxer-cache-duration", "0.1"},
+			{2 * time.Second, "core-idle", "yes"},
+		}, limit, nil},
+		{"paused for cache", []update{
+			{time.Second, "paused-for-cache", "yes"},
+			// 止まっている間の変化では時刻を更新しない
+			{5 * time.Second, "demuxer-cache-duration", "0.2"},
+		}, time.Second + limit, errStalled},
+		{"recovered", []update{
+			{0, "paused-for-cache", "yes"},
+			{5 * time.Second, "paused-for-cache", "no"},
+		}, time.Minute, nil},
+		{"stalled again", []update{
+			{0, "paused-for-cache", "yes"},
+			{5 * time.Second, "paused-for-cache", "no"},
+			{8 * time.Second, "paused-for-cache", "yes"},
+		}, 8*time.Second + limit - time.Second, nil},
+		{"eof", []update{
+			{0, "eof-reached", "yes"},
+		}, 0, errUnexpectedEOF},
+	} {
+		var d StallDetector
+		for _, u := range c.updates {
+			d.Update(u.name, u.data, start.Add(u.after))
+		}
+		if got := d.Check(start.Add(c.check), limit); got != c.want {
+			t.Errorf("%s: %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+// 読み込み直した時は途切れていた状態を忘れる
+func TestStallDetectorReset(t *testing.T) {
+	now := time.Date(2026, time.July, 6, 12, 0, 0, 0, jst)
+	var d StallDetector
+	d.Update("paused-for-cache", "yes", now)
+	d.Update("eof-reached", "yes", now)
+	d.Reset()
+	if err := d.Check(now.Add(time.Hour), time.Second); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sakaisatoru/go_radio_raspi/mpvctl"
+	"io"
+	"local.packages/irremote"
+	"local.packages/volume"
+	"sort"
+)
+
+const (
+	// 入力によらない出来事。操作と同じく transitions で遷移先を決める
+	eventAlarm    string = "!alarm"    // アラームの時刻になった
+	eventEnded    string = "!ended"    // オンデマンドの番組が終わった
+	eventGiveUp   string = "!giveup"   // 再生できるURLが無かった
+	eventIdle     string = "!idle"     // 入力の無いまま idle_timeouts の時間が経った
+	eventFound    string = "!found"    // 局の検索で見つかった
+	eventEpisodes string = "!episodes" // オンデマンドの番組の回の一覧を読み込んだ
+)
+
+// Guard 遷移の条件。Name は DOT に書き出す
+type Guard struct {
+	Name string
+	Test func(v *RadioState) bool
+}
+
+// Transition From の状態（空なら全ての状態）で Action を実行し、Guard を満たしていれば
+// Next へ移る。Guard は操作の前に調べる。上から順に調べて最初に合ったものを使う。
+type Transition struct {
+	From   []StateCode
+	Action string
+	Guard  *Guard
+	Next   StateCode
+}
+
+// StateHooks 状態に入る時と出る時に行う処理
+type StateHooks struct {
+	Enter func(v *RadioState)
+	Exit  func(v *RadioState)
+}
+
+var (
+	guardRadioOn = &Guard{"radio on", func(v *RadioState) bool {
+		return v.radioEnable
+	}}
+	guardPlaying = &Guard{"playing", func(v *RadioState) bool {
+		return v.IsRadioEnable() || v.IsTuning()
+	}}
+	guardSeekable = &Guard{"seekable", func(v *RadioState) bool {
+		return v.onDemand && !v.playlist
+	}}
+	guardPlaylist = &Guard{"playlist", func(v *RadioState) bool {
+		return v.playlist && !v.trackSkip
+	}}
+	guardEpisodic = &Guard{"episodic", func(v *RadioState) bool {
+		_, _, ok := LookupEpisodeResolver(v.CurrentStationURL())
+		return ok
+	}}
+	guardVolumeMin = &Guard{"volume min", func(v *RadioState) bool {
+		return volume.Get() == mpvctl.VolumeMin
+	}}
+	guardAlarmSleepOn = &Guard{"alarm&sleep on", func(v *RadioState) bool {
+		return v.tokeiState == (tokeiAlarmOn | tokeiSleepOn)
+	}}
+	guardMenuTop = &Guard{"menu top", func(v *RadioState) bool {
+		return v.menu.IsTop()
+	}}
+	guardMenuTopRadioOn = &Guard{"menu top, radio on", func(v *RadioState) bool {
+		return v.menu.IsTop() && v.radioEnable
+	}}
+	guardHistory = &Guard{"history", func(v *RadioState) bool {
+		return v.HasHistory()
+	}}
+	guardQueries = &Guard{"queries", func(v *RadioState) bool {
+		return len(config.DirectoryQueries) > 0
+	}}
+
+	// 状態の遷移表
+	transitions = []Transition{
+		// オンデマンドの番組は回の一覧を読み込む間は選局に留まり、!episodes で回の選択へ移る
+		{From: []StateCode{stateTuneMode}, Action: "tune", Guard: guardEpisodic, Next: stateTuneMode},
+		{Action: "tune", Next: stateVolumeSet},
+		{Action: "preset", Next: stateVolumeSet},
+		{Action: "toggle", Guard: guardPlaying, Next: stateNormalMode},
+		{Action: "toggle", Next: stateVolumeSet},
+		{Action: "voldown", Guard: guardVolumeMin, Next: stateNormalMode},
+		{Action: "off", Next: stateNormalMode},
+		{Action: "home", Guard: guardRadioOn, Next: stateVolumeSet},
+		{Action: "home", Next: stateNormalMode},
+		// 手元の音声ファイルは音量調整の中で曲送りを始め、もう一度押すと選局へ移る
+		{From: []StateCode{stateVolumeSet}, Action: "station", Guard: guardPlaylist, Next: stateVolumeSet},
+		{Action: "station", Guard: guardSeekable, Next: statePlayback},
+		{Action: "station", Next: stateTuneMode},
+		{Action: "tunemode", Next: stateTuneMode},
+		{Action: "function", Next: stateSelectFunction},
+		{Action: "alarmcycle", Guard: guardAlarmSleepOn, Next: stateAlarmHourSet},
+		{Action: "alarmmin", Next: stateAlarmMinSet},
+		{Action: "diagnostics", Next: stateDiagnostics},
+		{Action: "irlearn", Next: stateIRLearn},
+		{Action: "search", Guard: guardQueries, Next: stateDirQuery},
+		{Action: "play", Next: stateVolumeSet},
+		{Action: "settings", Next: stateSettings},
+		{Action: "history", Guard: guardHistory, Next: stateHistory},
+		{Action: "back", Guard: guardMenuTopRadioOn, Next: stateVolumeSet},
+		{Action: "back", Guard: guardMenuTop, Next: stateNormalMode},
+
+		{Action: eventAlarm, Next: stateVolumeSet},
+		{Action: eventEnded, Next: stateNormalMode},
+		{From: []StateCode{stateDirQuery}, Action: eventFound, Next: stateDirBrowse},
+		{From: []StateCode{stateTuneMode}, Action: eventEpisodes, Next: stateEpisodeSelect},
+		{From: []StateCode{stateVolumeSet, statePlayback}, Action: eventGiveUp, Next: stateNormalMode},
+		{Action: eventIdle, Guard: guardRadioOn, Next: stateVolumeSet},
+		{Action: eventIdle, Next: stateNormalMode},
+	}
+
+	// 状態ごとの初期化と後始末
+	stateHooks = map[StateCode]StateHooks{
+		stateVolumeSet: {Exit: func(v *RadioState) {
+			v.trackSkip = false
+		}},
+		stateDirQuery: {
+			Enter: func(v *RadioState) {
+				v.dirResults = nil
+				infomation.Update(0, config.DirectoryQueries[v.dirQueryPos].Label)
+			},
+			Exit: func(v *RadioState) {
+				// 検索中に出たら検索をやめる
+				cancelFetch()
+			},
+		},
+		stateTuneMode: {Exit: func(v *RadioState) {
+			// 回の一覧の読み込み中に出たら読み込みをやめる
+			cancelFetch()
+		}},
+		stateDirBrowse: {Enter: func(v *RadioState) {
+			v.showDirResult()
+		}},
+		stateEpisodeSelect: {
+			Enter: func(v *RadioState) {
+				v.showEpisode()
+			},
+			Exit: func(v *RadioState) {
+				// 回の一覧は選局の度に読み直す
+				v.episodes = nil
+			},
+		},
+		stateDiagnostics: {Enter: func(v *RadioState) {
+			v.diagPos = 0
+			infomation.Update(0, diagPages[0].label)
+		}},
+		stateIRLearn: {Enter: func(v *RadioState) {
+			v.irPages = irLearnPages()
+			v.irPos = 0
+			v.irLearned = irremote.Code{}
+			infomation.Update(0, v.irPages[0])
+		}},
+		stateAlarmHourSet: {Exit: saveAlarmTime},
+		stateAlarmMinSet:  {Exit: saveAlarmTime},
+		stateSettings: {
+			Enter: func(v *RadioState) {
+				v.menu.Open(settingsMenu(v))
+				v.showMenu()
+			},
+			Exit: func(v *RadioState) {
+				v.menu.Close()
+			},
+		},
+		stateHistory: {
+			Enter: func(v *RadioState) {
+				// 1つ前の局から選ぶ
+				v.historyPos = 1
+				v.showHistory()
+			},
+			Exit: (*RadioState).leaveHistory,
+		},
+	}
+)
+
+// matches 遷移の対象の状態かどうかを返す
+func (t *Transition) matches(s StateCode, action string) bool {
+	if t.Action != action {
+		return false
+	}
+	if len(t.From) == 0 {
+		return true
+	}
+	for _, f := range t.From {
+		if f == s {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupTransition 状態 s で action を実行した時の遷移を返す。遷移しなければ false を返す
+func lookupTransition(v *RadioState, s StateCode, action string) (Transition, bool) {
+	for _, t := range transitions {
+		if t.matches(s, action) && (t.Guard == nil || t.Guard.Test(v)) {
+			return t, true
+		}
+	}
+	return Transition{}, false
+}
+
+// Event 入力によらない出来事による遷移を行う
+func (v *RadioState) Event(name string) {
+	if t, ok := lookupTransition(v, v.currState, name); ok {
+		v.TransitionState(t.Next)
+	}
+}
+
+// exportDot 設定ファイルの keymap を反映した状態遷移図を書き出す
+func exportDot(w io.Writer) error {
+	var err error
+	config, err = LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	registerActions()
+	k, err := KeymapNew(config.Keymap)
+	if err != nil {
+		return err
+	}
+	return WriteDot(w, k)
+}
+
+// WriteDot 遷移表と keymap から状態遷移図を Graphviz の DOT で書き出す。
+// 辺には入力と操作、条件を書く。条件付きの遷移は条件を満たさない場合の遷移も書く。
+func WriteDot(w io.Writer, k Keymap) error {
+	names := make([]string, 0, len(stateNames))
+	for n := range stateNames {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool { return stateNames[names[i]] < stateNames[names[j]] })
+	buttons := make([]string, 0, len(buttonNames))
+	for n := range buttonNames {
+		buttons = append(buttons, n)
+	}
+	sort.Slice(buttons, func(i, j int) bool { return buttonNames[buttons[i]] < buttonNames[buttons[j]] })
+
+	fmt.Fprintln(w, "digraph radio {")
+	fmt.Fprintln(w, "\tnode [shape=box];")
+	for _, n := range names {
+		fmt.Fprintf(w, "\t%s;\n", n)
+	}
+	for _, n := range names {
+		s := stateNames[n]
+		for _, b := range buttons {
+			a := k.Lookup(s, buttonNames[b])
+			if a == "" {
+				continue
+			}
+			name, _ := parseAction(a)
+			writeDotEdges(w, s, b+": "+a, name)
+		}
+	}
+	for _, e := range []string{eventAlarm, eventEnded, eventGiveUp, eventIdle, eventFound, eventEpisodes} {
+		for _, n := range names {
+			writeDotEdges(w, stateNames[n], e, e)
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// writeDotEdges 状態 s で action を実行した時の遷移を辺として書く。遷移しない入力は自分への辺とする。
+// 出来事（! で始まるもの）は遷移するものだけを書く。
+func writeDotEdges(w io.Writer, s StateCode, label string, action string) {
+	from := stateName(s)
+	for _, t := range transitions {
+		if !t.matches(s, action) {
+			continue
+		}
+		if t.Guard == nil {
+			fmt.Fprintf(w, "\t%s -> %s [label=\"%s\"];\n", from, stateName(t.Next), label)
+			return
+		}
+		fmt.Fprintf(w, "\t%s -> %s [label=\"%s [%s]\"];\n", from, stateName(t.Next), label, t.Guard.Name)
+	}
+	if action[0] != '!' {
+		fmt.Fprintf(w, "\t%s -> %s [label=\"%s\", style=dashed];\n", from, from, label)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 既定の割り当ての状態遷移図は testdata/statemachine.dot と同じで、何度書いても変わらない
+func TestWriteDot(t *testing.T) {
+	registerActions()
+	k, err := KeymapNew(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a, b bytes.Buffer
+	if err := WriteDot(&a, k); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteDot(&b, k); err != nil {
+		t.Fatal(err)
+	}
+	if a.String() != b.String() {
+		t.Error("output is not stable")
+	}
+	want, err := os.ReadFile("testdata/statemachine.dot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.String() != string(want) {
+		t.Errorf("testdata/statemachine.dot is out of date; regenerate it as described in README:\n%s", a.String())
+	}
+	for n := range stateNames {
+		if !strings.Contains(a.String(), "\t"+n+";\n") {
+			t.Errorf("state %s not in the graph", n)
+		}
+	}
+}
+
+// 既定の割り当ての操作は全て登録されていて、遷移表の操作も登録されているか出来事である。
+// どの状態も normal から辿れ、他の状態へ出られる
+func TestTransitionsCover(t *testing.T) {
+	registerActions()
+	k := KeymapDefault()
+	events := map[string]bool{eventAlarm: true, eventEnded: true, eventGiveUp: true,
+		eventIdle: true, eventFound: true, eventEpisodes: true}
+
+	for _, tr := range transitions {
+		if _, ok := actions[tr.Action]; !ok && !events[tr.Action] {
+			t.Errorf("transition on unknown action %q", tr.Action)
+		}
+	}
+
+	// ガードは満たせるものとして、状態ごとに遷移先を集める
+	next := map[StateCode][]StateCode{}
+	for _, s := range stateNames {
+		var names []string
+		for _, a := range k[s] {
+			name, _ := parseAction(a)
+			if _, ok := actions[name]; !ok {
+				t.Errorf("%s: unknown action %q", stateName(s), a)
+			}
+			names = append(names, name)
+		}
+		for e := range events {
+			names = append(names, e)
+		}
+		for _, name := range names {
+			for _, tr := range transitions {
+				if tr.matches(s, name) && tr.Next != s {
+					next[s] = append(next[s], tr.Next)
+				}
+			}
+		}
+		if len(next[s]) == 0 {
+			t.Errorf("%s: no transition out of the state", stateName(s))
+		}
+	}
+
+	reached := map[StateCode]bool{stateNormalMode: true}
+	queue := []StateCode{stateNormalMode}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		for _, n := range next[s] {
+			if !reached[n] {
+				reached[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+	for n, s := range stateNames {
+		if !reached[s] {
+			t.Errorf("%s: not reachable from normal", n)
+		}
+	}
+}
+
+// 遷移は上から順に調べ、条件を満たした最初のものを使う
+func TestLookupTransition(t *testing.T) {
+	testRadio(t, `{}`)
+	v := radioState
+	for _, c := range []struct {
+		s      StateCode
+		action string
+		ok     bool
+		want   StateCode
+	}{
+		{stateNormalMode, "toggle", true, stateVolumeSet},
+		{stateNormalMode, "home", true, stateNormalMode},
+		{stateNormalMode, eventIdle, true, stateNormalMode},
+		{stateNormalMode, "history", false, 0},
+		{stateDirQuery, eventFound, true, stateDirBrowse},
+		{stateNormalMode, eventFound, false, 0},
+		{stateVolumeSet, "next", false, 0},
+	} {
+		tr, ok := lookupTransition(v, c.s, c.action)
+		if ok != c.ok || (ok && tr.Next != c.want) {
+			t.Errorf("%s %s: %s %v", stateName(c.s), c.action, stateName(tr.Next), ok)
+		}
+	}
+}
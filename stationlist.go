@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/sakaisatoru/go_mpvradio/netradio"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	extAlternate string = "#EXTALT:"
+
+	// 局の並び
+	stationSortFile  string = "file"  // 局リストの順
+	stationSortName  string = "name"  // 局名の順
+	stationSortGroup string = "group" // グループの順、同じグループの中は局名の順
+)
+
+// StationInfo 局情報。Url に加えて予備のURL（ミラーや別のビットレート等）を持てる。
+//
+//	#EXTINF:-1,klassikradio.de / Klassik Radio Live
+//	http://live.streams.klassikradio.de/klassikradio-deutschland/stream/mp3
+//	#EXTALT:http://stream.klassikradio.de/live/mp3-128/
+type StationInfo struct {
+	netradio.StationInfo
+	Group      string // #EXTINF の "/" より前（国や放送局等）
+	Alternates []string
+	current    int // 最後に再生できたURLの添字
+	trying     int // 選局で試しているURLの添字
+	order      int // 局リストの何番目か
+}
+
+// Urls 予備を含めた全てのURLを返す
+func (s *StationInfo) Urls() []string {
+	return append([]string{s.Url}, s.Alternates...)
+}
+
+// CurrentUrl 再生に使う（選局中は試している）URLを返す
+func (s *StationInfo) CurrentUrl() string {
+	return s.Urls()[s.trying]
+}
+
+// SetStationURLs 最後に再生できたURLを設定ファイルから戻す。局は先頭のURLで区別する
+func SetStationURLs(list []StationInfo, m map[string]int) {
+	for i := range list {
+		if n, ok := m[list[i].Url]; ok && n > 0 && n < len(list[i].Urls()) {
+			list[i].current = n
+			list[i].trying = n
+		}
+	}
+}
+
+// ReadStationList 局リストを読み込んで局情報のスライスを返す。
+// 文字コードを判定して UTF-8 以外であれば変換してから解釈する。
+func ReadStationList(path string, column int) ([]StationInfo, TextEncoding, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, encASCII, err
+	}
+	s, enc, err := DecodeText(b)
+	if err != nil {
+		return nil, enc, err
+	}
+	log.Printf("%s: 文字コード %s", path, enc)
+	return ParseStationList(s, column), enc, nil
+}
+
+// SortStations 局を order の順に並べ替える。局リストの順には並べ替えた後でも戻せる。
+func SortStations(list []StationInfo, order string) {
+	// 局リストの順では全て同じ値として、order だけで並べる
+	key := func(s *StationInfo) string { return "" }
+	switch order {
+	case stationSortName:
+		key = func(s *StationInfo) string { return strings.ToLower(strings.TrimSpace(s.Name)) }
+	case stationSortGroup:
+		key = func(s *StationInfo) string {
+			return strings.ToLower(s.Group) + "\x00" + strings.ToLower(strings.TrimSpace(s.Name))
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := key(&list[i]), key(&list[j])
+		if a != b {
+			return a < b
+		}
+		return list[i].order < list[j].order
+	})
+}
+
+var (
+	// 局リストに書く値から行を分ける文字と、グループと局名の区切りを除く
+	m3uNameReplacer  = strings.NewReplacer("\r", " ", "\n", " ", "/", "-")
+	m3uGroupReplacer = strings.NewReplacer("\r", " ", "\n", " ", "/", "-", ",", " ")
+)
+
+// StationEntry 局リストに書き込む m3u の1局分の記述を返す。外から得た値でも1局分の
+// 2行に収まり、グループと局名の区切りが崩れない様に置き換える。URL は先頭の行だけを使う
+func StationEntry(group, name, url string) string {
+	url, _, _ = strings.Cut(strings.TrimSpace(url), "\n")
+	return fmt.Sprintf("#EXTINF:-1,%s / %s\n%s\n",
+		strings.TrimSpace(m3uGroupReplacer.Replace(group)),
+		strings.TrimSpace(m3uNameReplacer.Replace(name)),
+		strings.TrimSpace(url))
+}
+
+// AppendStation 局リストの末尾に局を追加する。文字コードは既存のファイルにあわせる。
+func AppendStation(path string, enc TextEncoding, entry string) error {
+	b, err := EncodeText("\n"+entry, enc)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(b); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ParseStationList m3u 形式の文字列から局情報を取り出す
+func ParseStationList(text string, column int) []StationInfo {
+	var stlist []StationInfo
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	f := false
+	name := ""
+	group := ""
+	extflag := false
+
+	for scanner.Scan() {
+		s := strings.TrimLeft(scanner.Text(), " ")
+		s = strings.TrimRight(s, "\r")
+		if strings.Contains(s, "#EXTM3U") {
+			extflag = true
+			continue
+		}
+		if strings.Contains(s, "#EXTINF:") && extflag {
+			// 局情報をnameへ退避する
+			f = true
+			group, name, _ = strings.Cut(s, "/")
+			name = strings.Trim(name, " ")
+			if i := strings.Index(group, ","); i >= 0 {
+				group = strings.Trim(group[i+1:], " ")
+			}
+			continue
+		}
+		if strings.HasPrefix(s, extAlternate) {
+			// 直前の局に予備のURLを加える
+			if u := strings.Trim(s[len(extAlternate):], " "); u != "" && len(stlist) > 0 {
+				last := &stlist[len(stlist)-1]
+				last.Alternates = append(last.Alternates, u)
+			}
+			continue
+		}
+		if len(s) != 0 {
+			if s[:1] == "#" {
+				continue
+			}
+			stmp := StationInfo{}
+			stmp.Url = s
+			if f {
+				f = false
+				// 局名を得る。UTF-8 対応で rune で数える。
+				// 使用するキャラクタ表示器の桁数にあわせてトリミングも行う。
+				stmp.Name = string([]rune(name + "                ")[:column])
+				stmp.Group = group
+			}
+			stmp.order = len(stlist)
+			stlist = append(stlist, stmp)
+		}
+	}
+	return stlist
+}
@@ -1,62 +1,235 @@
 package main
 
 import (
-	"github.com/sakaisatoru/go_mpvradio/netradio"
-	"github.com/sakaisatoru/go_radio_raspi/mpvctl"
+	"context"
+	"errors"
 	"local.packages/volume"
 	"log"
-	"strings"
+	"time"
 )
 
-func tune() {
-	var (
-		stationURL string
-		err        error = nil
-	)
+// tuneResult 非同期に求めた再生URL。seq が古いものは取り消された選局の結果として捨てる。
+type tuneResult struct {
+	seq      int
+	resolved Resolved
+	err      error
+}
+
+const (
+	tuneRetryWaitMax time.Duration = 2 * time.Minute // やり直すまでの待ち時間の上限
+)
+
+var (
+	tuneSeq        int
+	tuneCancel     context.CancelFunc = func() {}
+	tuneDone                          = make(chan tuneResult)
+	tuneRetries    int                // 選局をやり直した回数
+	tuneRetryTimer *time.Timer        // 選局をやり直すまでの待ち
+	tuneDiag       TuneDiagnostics
+	tuneResolve    = ResolveStationURL // 再生するURLを求める。記録の再生では問い合わせずにそのまま返す
+
+	errStreamStopped = errors.New("mpv: 再生が止まりました")
+)
+
+func init() {
+	tuneRetryTimer = time.NewTimer(time.Hour)
+	tuneRetryTimer.Stop()
+}
 
+func tune() {
 	// 選局に変更がなければ戻る
 	if radioState.IsRadioEnable() && !radioState.IsCannelChange() {
 		return
 	}
-	m := radioState.CurrentStationName()
-	infomation.Update(0, m)
-
-	args := strings.Split(radioState.CurrentStationURL(), "/")
-	if args[0] == "plugin:" {
-		switch args[1] {
-		case "afn.py":
-			stationURL, err = netradio.AFNGetUrlWithApi(args[2])
-			if err != nil {
-				log.Println(err)
-				return
-			}
-		case "radiko.py":
-			var err error
-			for i := 0; i < 3; i++ {
-				// エラーの際は認証トークンの期限切れを見越して２回再挑戦する
-				err = radikoproxy.RadikoGetUrl(args[2])
-				if err == nil {
-					break
-				}
-			}
-			if err != nil {
-				log.Println(err)
-				return
-			}
-
-			if radikoproxy.IsStop() {
-				radikoproxy.Start()
-			}
-			stationURL = radikoproxy.GetProxyAddress()
-		default:
-			break
-		}
-	} else {
-		stationURL = radioState.CurrentStationURL()
-	}
-
-	mpvctl.Setvol(volume.Get())
-	mpvctl.Loadfile(stationURL)
+	// 同じ局を選局中なら戻る
+	if radioState.IsTuning() && radioState.TuningIndex() == radioState.CurrentStationIndex() {
+		return
+	}
+	infomation.Update(0, radioState.CurrentStationName())
+	tuneStation()
+}
+
+// tuneStation 現在の局の選局を始める。再生できなければ予備のURLを順に試す。
+func tuneStation() {
+	tuneRetries = 0
+	radioState.SetReconnecting(false)
+	infomation.ClearError()
+	radioState.ResetStationURL()
+	resolveStation()
+}
+
+// resolveStation 現在の局の現在のURLを求める処理を非同期に始める。
+// 結果は tuneDone を通して main の select ループで tuneCompleted が受け取る。
+func resolveStation() {
+	tuneCancel()
+	tuneSeq++
+	seq := tuneSeq
+	ctx, cancel := context.WithTimeout(context.Background(),
+		time.Duration(config.TuneTimeout)*time.Second)
+	tuneCancel = cancel
+
+	u := radioState.TuningStationURL()
+	radioState.TuningStart()
+	go func() {
+		r, err := tuneResolve(ctx, u)
+		tuneDone <- tuneResult{seq: seq, resolved: r, err: err}
+	}()
+}
+
+// CancelTune 選局中であれば取り消す
+func CancelTune() {
+	if !radioState.IsTuning() {
+		return
+	}
+	tuneCancel()
+	tuneRetryTimer.Stop()
+	tuneSeq++
+	radioState.TuningEnd()
+}
+
+// tuneCompleted 求めたURLを mpv に読み込ませる。求められなければ予備のURLを試す。
+func tuneCompleted(r tuneResult) {
+	if r.seq != tuneSeq {
+		// 取り消された選局
+		return
+	}
+	tuneCancel()
+	if r.err != nil {
+		tuneFailed(r.err)
+		return
+	}
+
+	radioState.TuningEnd()
+	mpvSetvol(volume.Get())
+	if err := loadResolved(r.resolved); err != nil {
+		tuneGiveUp(tuneDiag.Record(err), err)
+		return
+	}
 	radioState.RadioEnable()
 	radioState.CannelUpdate()
+	radioState.pushHistory()
+	radioState.StationLoaded()
+	streamStall.Reset()
+	radioState.SetOnDemand(r.resolved.OnDemand, r.resolved.Playlist)
+}
+
+// tuneStopped 再生中に mpv が待機状態へ戻った時の処理。err は mpv の end-file で、
+// 分からなければ nil
+func tuneStopped(err error) {
+	if radioState.IsTuning() {
+		// 選局中であれば新しい局の結果を待つ
+		return
+	}
+	var eferr *MpvEndFileError
+	loadFailed := errors.As(err, &eferr) && eferr.Reason == "error"
+	if radioState.IsOnDemand() && !loadFailed {
+		// 番組が終わった
+		mpvStop()
+		radioState.Event(eventEnded)
+		return
+	}
+	if err == nil {
+		err = errStreamStopped
+	}
+	if radioState.IsPlaying() {
+		// 配信が途切れた
+		radioState.SetReconnecting(true)
+	}
+	tuneFailed(err)
+}
+
+// tuneAudioStarted 読み込んだURLで音が出始めた。選局のやり直しは済んだものとする。
+// 予備のURLで音が出たらそれを覚えておく。
+func tuneAudioStarted() {
+	radioState.AudioStarted()
+	if radioState.StationURLWorked() {
+		saveStationURLs()
+	}
+	if tuneRetries > 0 {
+		infomation.ClearError()
+		infomation.Update(0, radioState.TuningStationName())
+	}
+	tuneRetries = 0
+}
+
+// tuneCheckAudio 読み込んでからしばらく音が出なければ失敗とみなす
+func tuneCheckAudio() {
+	if radioState.IsSilent(noAudioDuration) {
+		tuneFailed(errNoAudio)
+	}
+}
+
+// tuneCheckStall 再生中の配信が途切れていれば再接続する。オンデマンドの番組は終わりがあるので見ない。
+func tuneCheckStall() {
+	if config.StallTimeout <= 0 || !radioState.IsPlaying() || radioState.IsOnDemand() {
+		return
+	}
+	if err := streamStall.Check(timeNow(), time.Duration(config.StallTimeout)*time.Second); err != nil {
+		streamStall.Reset()
+		radioState.SetReconnecting(true)
+		tuneFailed(err)
+	}
+}
+
+// tuneFailed 選局の失敗を記録し、予備のURLへ切り替える。全てのURLで失敗していれば
+// 待ち時間を倍にしながら制限回数まで選局をやり直す。
+func tuneFailed(err error) {
+	k := tuneDiag.Record(err)
+	log.Printf("%s: %s %v", radioState.TuningStationName(), k, err)
+
+	if radioState.NextStationURL() {
+		log.Printf("%s: 予備のURLへ切り替えます %s",
+			radioState.TuningStationName(), radioState.TuningStationURL())
+		resolveStation()
+		return
+	}
+	if tuneRetries >= config.TuneRetryLimit {
+		radioState.TuningEnd()
+		tuneGiveUp(k, err)
+		return
+	}
+
+	wait := tuneRetryWait(tuneRetries)
+	tuneRetries++
+	tuneDiag.Retries++
+	log.Printf("%s: %v 後に選局をやり直します (%d/%d)",
+		radioState.TuningStationName(), wait, tuneRetries, config.TuneRetryLimit)
+
+	// 待っている間は音を止めておく。ラジオは入ったままとする。
+	mpvSend("{\"command\": [\"stop\"]}\x0a")
+	tuneCancel()
+	tuneSeq++
+	radioState.TuningStart()
+	infomation.ShowTuneError(k, wait)
+	tuneRetryTimer.Reset(wait)
+}
+
+// tuneRetryWait n 回目のやり直しまでの待ち時間。tune_retry_wait から倍にしていき tuneRetryWaitMax で止める
+func tuneRetryWait(n int) time.Duration {
+	wait := time.Duration(config.TuneRetryWait) * time.Second << n
+	if wait > tuneRetryWaitMax || wait <= 0 {
+		wait = tuneRetryWaitMax
+	}
+	return wait
+}
+
+// tuneRetry 待ち時間が過ぎたので最初のURLから選局をやり直す
+func tuneRetry() {
+	if !radioState.IsTuning() {
+		// 待っている間に取り消された
+		return
+	}
+	radioState.FirstStationURL()
+	resolveStation()
+}
+
+// tuneGiveUp 全てのURLで再生できなかった
+func tuneGiveUp(k TuneErrorKind, err error) {
+	log.Printf("%s: 再生できるURLがありません (%s: %v)", radioState.TuningStationName(), k, err)
+	tuneDiag.GiveUps++
+	tuneRetries = 0
+	radioState.SetReconnecting(false)
+	mpvStop()
+	radioState.Event(eventGiveUp)
+	infomation.ShowTuneError(k, tuneErrorDuration)
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// 予備のURLへは試すだけ切り替え、音が出たURLだけを次の選局でも使う
+func TestStationURLFailover(t *testing.T) {
+	now := time.Date(2026, time.July, 6, 12, 0, 0, 0, jst)
+	setGlobal(t, &timeNow, func() time.Time { return now })
+	v := RadioStateNew()
+	v.stationList = []StationInfo{testStation(0, "http://a/", "http://b/", "http://c/")}
+	v.stationListLen = 1
+
+	v.ResetStationURL()
+	if u := v.CurrentStationURL(); u != "http://a/" {
+		t.Fatalf("first %s", u)
+	}
+	if !v.NextStationURL() || v.CurrentStationURL() != "http://b/" {
+		t.Fatalf("failover %s", v.CurrentStationURL())
+	}
+	if !v.StationURLWorked() || v.StationURLWorked() {
+		t.Error("StationURLWorked should report the change once")
+	}
+
+	// 全て失敗しても、次の選局は最後に音が出たURLから始める
+	v.ResetStationURL()
+	for v.NextStationURL() {
+	}
+	if v.stationList[0].current != 1 {
+		t.Errorf("current %d after failures", v.stationList[0].current)
+	}
+	v.ResetStationURL()
+	if u := v.CurrentStationURL(); u != "http://b/" {
+		t.Errorf("next tune %s", u)
+	}
+	v.FirstStationURL()
+	if u := v.CurrentStationURL(); u != "http://a/" {
+		t.Errorf("retry %s", u)
+	}
+
+	// しばらく再生できていたなら途絶えた後に予備のURLを試し直す
+	for v.NextStationURL() {
+	}
+	now = now.Add(stationAliveDuration + time.Second)
+	if !v.NextStationURL() {
+		t.Error("URLs not retried after the station had been playing")
+	}
+}
+
+// 音が出た予備のURLは設定ファイルに書き込み、読み直した局リストに戻す
+func TestStationURLSaved(t *testing.T) {
+	testRadio(t, `{}`)
+	var keys []string
+	setGlobal(t, &configUpdate, func(_ string, _ *Config, k ...string) error {
+		keys = append(keys, k...)
+		return nil
+	})
+	radioState.stationList[1].Alternates = []string{"http://127.0.0.1:8080/jazz24-alt"}
+	radioState.pos = 1
+	tune()
+	tuneCompleted(<-tuneDone)
+	// 先頭のURLでは音が出ないので予備のURLへ切り替える
+	tuneFailed(errNoAudio)
+	tuneCompleted(<-tuneDone)
+	tuneAudioStarted()
+	if len(keys) != 1 || keys[0] != "station_urls" {
+		t.Fatalf("saved %q", keys)
+	}
+	u := radioState.stationList[1].Url
+	if n := config.StationURLs[u]; n != 1 {
+		t.Fatalf("station_urls %v", config.StationURLs)
+	}
+
+	list := ParseStationList("#EXTM3U\n#EXTINF:-1,Jazz / Jazz24\n"+u+"\n#EXTALT:http://127.0.0.1:8080/jazz24-alt\n", 8)
+	SetStationURLs(list, config.StationURLs)
+	if got := list[0].CurrentUrl(); got != "http://127.0.0.1:8080/jazz24-alt" {
+		t.Errorf("restored %s", got)
+	}
+}
+
+func TestMpvLoadfile(t *testing.T) {
+	var sent string
+	setGlobal(t, &mpvSend, func(s string) error {
+		sent = s
+		return nil
+	})
+	if err := mpvLoadfile(`http://example.com/a"b\c`); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"command":["loadfile","http://example.com/a\"b\\c"]}` + "\n"; sent != want {
+		t.Errorf("sent %q, want %q", sent, want)
+	}
+}
+
+// 選局で他の局へ回している間に配信が途切れても、再生していた局の予備のURLへ切り替える
+func TestStreamDropWhileBrowsing(t *testing.T) {
+	testRadio(t, `{}`)
+	var sent []string
+	setGlobal(t, &mpvSend, func(s string) error {
+		sent = append(sent, s)
+		return nil
+	})
+
+	radioState.stationList[1].Alternates = []string{"http://127.0.0.1:8080/jazz24-alt"}
+	radioState.pos = 1
+	tune()
+	tuneCompleted(<-tuneDone)
+	tuneAudioStarted()
+
+	radioState.RunAction("tunemode")
+	radioState.RunAction("next")
+	if radioState.pos == 1 {
+		t.Fatal("pos did not move")
+	}
+	sent = nil
+	tuneStopped(nil)
+	select {
+	case res := <-tuneDone:
+		tuneCompleted(res)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no failover")
+	}
+	if got := strings.Join(sent, ""); !strings.Contains(got, "jazz24-alt") {
+		t.Errorf("sent %q", got)
+	}
+	if radioState.lastpos != 1 || radioState.stationList[radioState.pos].trying != 0 {
+		t.Errorf("lastpos %d, browsed station trying %d", radioState.lastpos, radioState.stationList[radioState.pos].trying)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/carlmjohnson/requests"
+	"net"
+	"net/http"
+	"time"
+)
+
+type TuneErrorKind int
+
+const (
+	tuneErrUnknown    TuneErrorKind = iota
+	tuneErrDNS                      // 名前解決の失敗
+	tuneErrHTTPClient               // HTTP 4xx
+	tuneErrHTTPServer               // HTTP 5xx
+	tuneErrAuth                     // 認証の失敗 (HTTP 401/403)
+	tuneErrTimeout                  // 応答が無い
+	tuneErrNoAudio                  // 読み込んだが音が出ない
+	tuneErrStall                    // 再生中に配信が途切れた
+	tuneErrKinds
+)
+
+const (
+	noAudioDuration   time.Duration = 15 * time.Second // 読み込んでから音が出るまでの制限
+	tuneErrorDuration time.Duration = 10 * time.Second // 選局を諦めた後にエラーを表示しておく時間
+)
+
+var (
+	tuneErrorName = [...]string{
+		"unknown",
+		"DNS",
+		"HTTP4xx",
+		"HTTP5xx",
+		"auth",
+		"timeout",
+		"noaudio",
+		"stall",
+	}
+	tuneErrorMessage = [...]int{
+		ErrorTuning,
+		ErrorDNS,
+		ErrorHTTPClient,
+		ErrorHTTPServer,
+		ErrorAuth,
+		ErrorTimeout,
+		ErrorNoAudio,
+		ErrorReconnect,
+	}
+
+	errNoAudio = errors.New("no audio after load")
+)
+
+func (k TuneErrorKind) String() string {
+	return tuneErrorName[k]
+}
+
+// Message エラーの種類に対応する LCD 表示用のメッセージ番号を返す
+func (k TuneErrorKind) Message() int {
+	return tuneErrorMessage[k]
+}
+
+// HTTPStatusError HTTP のステータスコードで失敗した事を表す
+type HTTPStatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return e.Status
+}
+
+// httpStatusError 応答のステータスコードから HTTPStatusError を作る
+func httpStatusError(resp *http.Response) error {
+	return &HTTPStatusError{Code: resp.StatusCode, Status: resp.Status}
+}
+
+// ClassifyTuneError 選局の失敗を種類に分ける
+func ClassifyTuneError(err error) TuneErrorKind {
+	var (
+		dnserr *net.DNSError
+		sterr  *HTTPStatusError
+		reserr *requests.ResponseError
+		neterr net.Error
+		eferr  *MpvEndFileError
+	)
+
+	code := 0
+	switch {
+	case err == nil:
+		return tuneErrUnknown
+	case errors.Is(err, errNoAudio):
+		return tuneErrNoAudio
+	case errors.As(err, &eferr):
+		// mpv の end-file。HTTP のエラーはステータスコードで分ける
+		var k TuneErrorKind
+		if k, code = classifyEndFile(eferr); code == 0 {
+			return k
+		}
+	case errors.Is(err, errStalled), errors.Is(err, errUnexpectedEOF), errors.Is(err, errStreamStopped):
+		return tuneErrStall
+	case errors.As(err, &dnserr):
+		return tuneErrDNS
+	case errors.Is(err, context.DeadlineExceeded):
+		return tuneErrTimeout
+	case errors.As(err, &neterr) && neterr.Timeout():
+		return tuneErrTimeout
+	case errors.As(err, &sterr):
+		code = sterr.Code
+	case errors.As(err, &reserr):
+		code = reserr.StatusCode
+	}
+
+	switch {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return tuneErrAuth
+	case code >= 500:
+		return tuneErrHTTPServer
+	case code >= 400:
+		return tuneErrHTTPClient
+	}
+	return tuneErrUnknown
+}
+
+// TuneDiagnostics 選局の失敗の記録。診断画面で表示する。
+type TuneDiagnostics struct {
+	Errors    [tuneErrKinds]int // 種類ごとの失敗の回数
+	Retries   int               // 再挑戦の回数
+	GiveUps   int               // 諦めた回数
+	LastError string
+	LastTime  time.Time
+}
+
+// Record 失敗を記録して種類を返す
+func (d *TuneDiagnostics) Record(err error) TuneErrorKind {
+	k := ClassifyTuneError(err)
+	d.Errors[k]++
+	d.LastError = fmt.Sprintf("%s: %v", k, err)
+	d.LastTime = timeNow()
+	return k
+}
+
+// Total 失敗の合計を返す
+func (d *TuneDiagnostics) Total() int {
+	n := 0
+	for _, c := range d.Errors {
+		n += c
+	}
+	return n
+}
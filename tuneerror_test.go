@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClassifyTuneError(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want TuneErrorKind
+	}{
+		{nil, tuneErrUnknown},
+		{fmt.Errorf("load: %w", errNoAudio), tuneErrNoAudio},
+		{errStalled, tuneErrStall},
+		{errUnexpectedEOF, tuneErrStall},
+		{errStreamStopped, tuneErrStall},
+		{&net.DNSError{Err: "no such host", Name: "a.example"}, tuneErrDNS},
+		{fmt.Errorf("resolve: %w", context.DeadlineExceeded), tuneErrTimeout},
+		{&HTTPStatusError{Code: 401, Status: "401 Unauthorized"}, tuneErrAuth},
+		{&HTTPStatusError{Code: 403, Status: "403 Forbidden"}, tuneErrAuth},
+		{&HTTPStatusError{Code: 404, Status: "404 Not Found"}, tuneErrHTTPClient},
+		{&HTTPStatusError{Code: 503, Status: "503 Service Unavailable"}, tuneErrHTTPServer},
+		// mpv の end-file
+		{&MpvEndFileError{Reason: "eof"}, tuneErrStall},
+		{&MpvEndFileError{Reason: "error", FileError: "loading failed",
+			Log: "Failed to open http://a.example/s: Failed to resolve hostname a.example"}, tuneErrDNS},
+		{&MpvEndFileError{Reason: "error", FileError: "loading failed",
+			Log: "http: HTTP error 404 Not Found"}, tuneErrHTTPClient},
+		{&MpvEndFileError{Reason: "error", FileError: "loading failed",
+			Log: "http: HTTP error 403 Forbidden"}, tuneErrAuth},
+		{&MpvEndFileError{Reason: "error", FileError: "loading failed",
+			Log: "http: HTTP error 502 Bad Gateway"}, tuneErrHTTPServer},
+		{&MpvEndFileError{Reason: "error", FileError: "loading failed",
+			Log: "tcp: Connection to tcp://a.example:80 failed: Connection timed out"}, tuneErrTimeout},
+		{&MpvEndFileError{Reason: "error", FileError: "unrecognized file format"}, tuneErrNoAudio},
+		{&MpvEndFileError{Reason: "error", FileError: "no audio or video data played"}, tuneErrNoAudio},
+		{&MpvEndFileError{Reason: "error", FileError: "loading failed"}, tuneErrUnknown},
+	} {
+		if got := ClassifyTuneError(c.err); got != c.want {
+			t.Errorf("%v: %s, want %s", c.err, got, c.want)
+		}
+	}
+}
+
+// 待ち時間は tune_retry_wait から倍にしていき、上限で止める
+func TestTuneRetryWait(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config = ConfigDefault()
+
+	for _, c := range []struct {
+		wait int
+		n    int
+		want time.Duration
+	}{
+		{2, 0, 2 * time.Second},
+		{2, 1, 4 * time.Second},
+		{2, 2, 8 * time.Second},
+		{2, 5, 64 * time.Second},
+		{2, 6, tuneRetryWaitMax},
+		{2, 70, tuneRetryWaitMax},
+		{0, 0, tuneRetryWaitMax},
+		{300, 0, tuneRetryWaitMax},
+	} {
+		config.TuneRetryWait = c.wait
+		if got := tuneRetryWait(c.n); got != c.want {
+			t.Errorf("wait %d, retry %d: %v, want %v", c.wait, c.n, got, c.want)
+		}
+	}
+}
+
+// 待機状態へ戻る度に、直前の end-file と止まる前のエラーのログを送る。
+// 止めた時や次の読み込みで終わった時は理由を送らない
+func TestMpvStopLoop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mpvsocket")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		for _, s := range []string{
+			`{"event":"property-change","id":1,"name":"idle-active","data":"yes"}`,
+			`{"request_id":0,"error":"success"}`,
+			`{"event":"start-file","playlist_entry_id":1}`,
+			`{"event":"log-message","prefix":"ffmpeg","level":"error","text":"http: HTTP error 404 Not Found\n"}`,
+			`{"event":"end-file","reason":"error","playlist_entry_id":1,"file_error":"loading failed"}`,
+			`{"event":"property-change","id":1,"name":"idle-active","data":"yes"}`,
+			`{"event":"start-file","playlist_entry_id":2}`,
+			`{"event":"end-file","reason":"eof","playlist_entry_id":2}`,
+			`{"event":"property-change","id":1,"name":"idle-active","data":"yes"}`,
+			`{"event":"start-file","playlist_entry_id":3}`,
+			`{"event":"end-file","reason":"stop","playlist_entry_id":3}`,
+			`{"event":"property-change","id":1,"name":"idle-active","data":"yes"}`,
+		} {
+			c.Write([]byte(s + "\n"))
+		}
+	}()
+
+	stopped := make(chan error)
+	go mpvStopLoop(path, stopped)
+	want := []string{
+		"<nil>",
+		"mpv: end-file error (loading failed): http: HTTP error 404 Not Found",
+		"mpv: end-file eof",
+		"<nil>",
+	}
+	for i, w := range want {
+		select {
+		case err := <-stopped:
+			if got := fmt.Sprint(err); got != w {
+				t.Errorf("stop %d: %q, want %q", i, got, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("stop %d: timed out", i)
+		}
+	}
+}
@@ -9,6 +9,10 @@ var (
 	volume      int8          = 0
 	visible     bool          = true
 	visibleSpan time.Duration = 700 * time.Millisecond
+	limit       int8          = -1 // 音量の上限 負なら mpvctl.VolumeMax
+
+	// 音量を mpv へ設定する。差し替えれば mpv 無しでも使える
+	Setvol = mpvctl.Setvol
 )
 
 func IsVisible() bool {
@@ -23,12 +27,30 @@ func Get() int8 {
 	return volume
 }
 
+// SetLimit 音量の上限を設定する。負なら mpvctl.VolumeMax までとする。
+// 今の音量が上限を超えていれば下げる。
+func SetLimit(n int8) {
+	limit = n
+	if m := Max(); volume > m {
+		volume = m
+		Setvol(volume)
+	}
+}
+
+// Max 音量の上限を返す
+func Max() int8 {
+	if limit < 0 || limit > mpvctl.VolumeMax {
+		return mpvctl.VolumeMax
+	}
+	return limit
+}
+
 func Increment() {
 	volume++
-	if volume > mpvctl.VolumeMax {
-		volume = mpvctl.VolumeMax
+	if m := Max(); volume > m {
+		volume = m
 	}
-	mpvctl.Setvol(volume)
+	Setvol(volume)
 }
 
 func Decrement() {
@@ -36,5 +58,5 @@ func Decrement() {
 	if volume <= mpvctl.VolumeMin {
 		volume = mpvctl.VolumeMin
 	}
-	mpvctl.Setvol(volume)
+	Setvol(volume)
 }